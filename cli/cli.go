@@ -6,17 +6,91 @@ import (
 	"strings"
 
 	"github.com/gandalf15/iplayerlinks/epinfo"
+	"github.com/gandalf15/iplayerlinks/filter"
+	"github.com/gandalf15/iplayerlinks/ipurl"
 )
 
-// Cli runs command line interface
-func Cli(url *string, audioDescribed *bool, signLang *bool) {
+// Options holds the command line flags
+type Options struct {
+	URL                      string
+	AudioDescribed, SignLang bool
+	// Verbose logs which extractor recognised each page.
+	Verbose bool
+	// SingleEpisode prints only the given episode instead of its whole list.
+	SingleEpisode bool
+	// Stream prints the links as soon as they are found instead of all of them at the end.
+	Stream bool
+	// MaxFetches caps the pages fetched at the same time, 0 means no cap.
+	MaxFetches int
+	// Filter selects the episodes to print.
+	Filter filter.Spec
+	// HTTP sets up the client fetching the pages.
+	HTTP epinfo.ClientOptions
+}
 
-	if *url == "" {
+// crawl prints the links of every show of a listing, each show under a "# title" line
+// which youtube-dl skips when reading the links from a file.
+func crawl(opts Options, f *filter.Filter) {
+	shows, err := epinfo.CrawlListing(opts.URL, opts.AudioDescribed, opts.SignLang)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var lines []string
+	for _, show := range shows {
+		if show.Err != nil {
+			log.Printf("%s: %s", show.Title, show.Err)
+		}
+		lines = append(lines, "# "+show.Title)
+		for _, v := range f.Apply(show.Series) {
+			for _, epi := range v {
+				lines = append(lines, epi.URL)
+			}
+		}
+	}
+	fmt.Print(strings.Join(lines, "\n"))
+}
+
+// Cli runs command line interface
+func Cli(opts Options) {
+	epinfo.SetMaxConcurrentFetches(opts.MaxFetches)
+	useHTTP(opts.HTTP)
+	f, err := opts.Filter.Compile()
+	if err != nil {
+		log.Fatal(err)
+	}
+	refuseSearch(opts.URL)
+	if opts.URL == "" {
 		log.Fatal("usage: ./iplayer -url=[iPlayer URL with episodes]")
+	} else if opts.Stream && !opts.SingleEpisode {
+		stream(opts, f)
+	} else if u, err := ipurl.Parse(opts.URL); err == nil && u.Kind == ipurl.Listing {
+		crawl(opts, f)
+	} else if opts.SingleEpisode {
+		episodes, err := epinfo.SingleEpisodeInfo(opts.URL, opts.AudioDescribed, opts.SignLang)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var epLinks []string
+		for _, v := range f.Apply(map[string][]epinfo.EpisodeInfo{"none": episodes}) {
+			for _, epi := range v {
+				epLinks = append(epLinks, epi.URL)
+			}
+		}
+		fmt.Print(strings.Join(epLinks, "\n"))
 	} else {
-		allSeries := epinfo.AllEpisodesInfo(*url, *audioDescribed, *signLang)
+		allSeries, diag, err := epinfo.AllEpisodesInfoWithDiagnostics(opts.URL, opts.AudioDescribed, opts.SignLang)
+		if opts.Verbose {
+			log.Printf("Episodes list: %s", diag.ListURL)
+			log.Printf("Series extractor: %q", diag.Series)
+			for sURL, name := range diag.Episodes {
+				log.Printf("Episodes extractor for %s: %q", sURL, name)
+			}
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
 		var epLinks []string
-		for _, v := range allSeries {
+		for _, v := range f.Apply(allSeries) {
 			for _, epi := range v {
 				epLinks = append(epLinks, epi.URL)
 			}
@@ -0,0 +1,249 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/config"
+	"github.com/gandalf15/iplayerlinks/download"
+	"github.com/gandalf15/iplayerlinks/epinfo"
+	"github.com/gandalf15/iplayerlinks/filter"
+	"github.com/gandalf15/iplayerlinks/ipurl"
+)
+
+// episodes scrapes the URL of opts and returns the episodes passing f, series by series in name order.
+func episodes(opts Options, f *filter.Filter) []epinfo.EpisodeInfo {
+	var shows []map[string][]epinfo.EpisodeInfo
+	refuseSearch(opts.URL)
+	if u, err := ipurl.Parse(opts.URL); err == nil && u.Kind == ipurl.Listing {
+		crawled, err := epinfo.CrawlListing(opts.URL, opts.AudioDescribed, opts.SignLang)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, show := range crawled {
+			if show.Err != nil {
+				log.Printf("%s: %s", show.Title, show.Err)
+			}
+			shows = append(shows, show.Series)
+		}
+	} else if opts.SingleEpisode {
+		found, err := epinfo.SingleEpisodeInfo(opts.URL, opts.AudioDescribed, opts.SignLang)
+		if err != nil {
+			log.Fatal(err)
+		}
+		shows = append(shows, map[string][]epinfo.EpisodeInfo{"none": found})
+	} else {
+		allSeries, _, err := epinfo.AllEpisodesInfoWithDiagnostics(opts.URL, opts.AudioDescribed, opts.SignLang)
+		if err != nil {
+			log.Fatal(err)
+		}
+		shows = append(shows, allSeries)
+	}
+	res := []epinfo.EpisodeInfo{}
+	for _, allSeries := range shows {
+		allSeries = f.Apply(allSeries)
+		names := []string{}
+		for name := range allSeries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			res = append(res, allSeries[name]...)
+		}
+	}
+	return res
+}
+
+// Download runs the download subcommand: it downloads the episodes of -url passing the filter
+// into -dir, each of them saved under the name the -template gives it.
+// The defaults come from the config.
+func Download(cfg config.Config, args []string) {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	opts := Options{}
+	fs.StringVar(&opts.URL, "url", "", "-url=[iPlayer URL with episodes]")
+	fs.BoolVar(&opts.AudioDescribed, "audioDescribed", cfg.Variant("ad"), "-audioDescribed=[bool]")
+	fs.BoolVar(&opts.SignLang, "signLang", cfg.Variant("sign"), "-signLang=[bool]")
+	fs.BoolVar(&opts.SingleEpisode, "singleEpisode", false, "-singleEpisode=[bool] only the episode of an episode URL")
+	fs.IntVar(&opts.MaxFetches, "maxFetches", cfg.RateLimit.MaxFetches,
+		"-maxFetches=[int] pages fetched at the same time, 0 for no limit")
+	dir := fs.String("dir", ".", "-dir=[download folder]")
+	template := fs.String("template", cfg.Downloader.Output, "-template=[{show}/{show} - {title}.{ext}]")
+	backend := download.Backend{Args: cfg.Downloader.Args}
+	fs.StringVar(&backend.Program, "backend", cfg.Downloader.Backend, "-backend=[youtube-dl or yt-dlp]")
+	quality := fs.String("quality", cfg.Downloader.Quality, "-quality=[best, 1080p, 720p, sd, audio or smallest:N MB per hour]")
+	fs.StringVar(&backend.Format, "format", cfg.Downloader.Format,
+		"-format=[format selector of the backend] instead of the quality, -quality replaces the one of the config")
+	sidecars := fs.Bool("nfo", cfg.Downloader.Metadata, "-nfo=[bool] write NFO files and artwork for media servers")
+	verify := fs.Bool("verify", cfg.Downloader.Verify, "-verify=[bool] check the size, duration and leftovers of the files")
+	retries := fs.Int("retries", cfg.Downloader.Retries, "-retries=[int] download the failed episodes again")
+	spaceCheck := fs.String("spaceCheck", cfg.Downloader.SpaceCheck,
+		"-spaceCheck=[warn, refuse or off] when the episodes may not fit in the free space")
+	maxSize := fs.String("maxSize", cfg.Downloader.MaxSize, "-maxSize=[50G] stop once the downloads take that much")
+	bandwidth := fs.String("bandwidth", cfg.RateLimit.Bandwidth, "-bandwidth=[2M] bytes per second of all downloads")
+	windows := fs.String("windows", strings.Join(cfg.Downloader.Windows, ","),
+		"-windows=[01:00-06:00,...] times of day the downloads run in")
+	subscription := fs.String("subscription", "", "-subscription=[name] the URL, filter and size cap of a subscription")
+	subs := cfg.Subtitles
+	fs.BoolVar(&subs.Enabled, "subs", subs.Enabled, "-subs=[bool] download subtitles")
+	subLangs := fs.String("subLangs", strings.Join(subs.Languages, ","), "-subLangs=[en,cy] subtitle languages")
+	fs.StringVar(&subs.Format, "subFormat", subs.Format, "-subFormat=[srt, vtt or ttml]")
+	fs.BoolVar(&subs.Only, "subsOnly", subs.Only, "-subsOnly=[bool] download the subtitles without the video")
+	fs.BoolVar(&subs.Mux, "mux", subs.Mux, "-mux=[bool] put the subtitles into an MKV file with ffmpeg")
+	audio := cfg.Audio
+	fs.BoolVar(&audio.Enabled, "audio", audio.Enabled, "-audio=[bool] keep only the audio")
+	fs.StringVar(&audio.Format, "audioFormat", audio.Format, "-audioFormat=[m4a, mp3 or opus]")
+	fs.BoolVar(&audio.Album, "album", audio.Album, "-album=[bool] save the series as albums: "+download.AlbumTemplate)
+	hooks := cfg.Hooks
+	fs.StringVar(&hooks.Episode, "hook", hooks.Episode, "-hook=[shell command run after every episode]")
+	fs.StringVar(&hooks.Batch, "batchHook", hooks.Batch, "-batchHook=[shell command run after all episodes]")
+	spec := FilterFlags(fs)
+	httpOpts := HTTPFlags(fs, cfg.HTTP)
+	fs.Parse(args)
+	useHTTP(*httpOpts)
+	backend.SetHTTP(*httpOpts)
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if *subscription != "" {
+		sub, err := cfg.Subscription(*subscription)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Flags given on the command line win over the subscription.
+		if !set["url"] {
+			opts.URL = sub.URL
+		}
+		*spec = overrideSpec(sub.Filter, *spec, set)
+		if sub.MaxSize != "" && !set["maxSize"] {
+			*maxSize = sub.MaxSize
+		}
+	}
+	maxBytes, err := download.ParseSize(*maxSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	limit, err := download.ParseSize(*bandwidth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	download.SetBandwidth(limit, cfg.RateLimit.MaxDownloads)
+	schedule, err := download.ParseSchedule(strings.Split(*windows, ","))
+	if err != nil {
+		log.Fatal(err)
+	}
+	known := false
+	for _, check := range download.SpaceChecks {
+		known = known || check == *spaceCheck
+	}
+	if !known {
+		log.Fatalf("unknown space check %q, use one of %s", *spaceCheck, strings.Join(download.SpaceChecks, ", "))
+	}
+	if err := audio.Check(); err != nil {
+		log.Fatal(err)
+	}
+	subs.Languages = nil
+	for _, lang := range strings.Split(*subLangs, ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			subs.Languages = append(subs.Languages, lang)
+		}
+	}
+	if subs.Only {
+		subs.Enabled = true
+	}
+	if err := subs.Check(); err != nil {
+		log.Fatal(err)
+	}
+	if opts.URL == "" {
+		log.Fatal("usage: ./iplayer download -url=[iPlayer URL with episodes] [-dir=folder]")
+	}
+	q, err := download.ParseQuality(*quality)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case backend.Format == "":
+	case set["quality"] && !set["format"]:
+		// A quality given on the command line wins over the format of the config.
+		backend.Format = ""
+	case set["quality"] || q.Name != download.Best:
+		log.Printf("Warning, the format %q is used instead of the quality %s", backend.Format, q)
+	}
+	backend.SetQuality(audio.Quality(q))
+	tmpl, err := download.ParseTemplate(*template)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if tmpl, err = audio.Template(tmpl); err != nil {
+		log.Fatal(err)
+	}
+	if *verify {
+		if err := tmpl.Resolvable(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	f, err := spec.Compile()
+	if err != nil {
+		log.Fatal(err)
+	}
+	epinfo.SetMaxConcurrentFetches(opts.MaxFetches)
+	jobs := download.Jobs(episodes(opts, f), *dir, tmpl)
+	preflight(backend, jobs, *dir, maxBytes, *spaceCheck)
+	runner := download.Runner{Backend: backend, Output: os.Stdout, Sidecars: *sidecars, Subtitles: subs,
+		Audio: audio, Hooks: hooks, Verify: *verify, MaxBytes: maxBytes,
+		Schedule: schedule}
+	results := runner.Run(context.Background(), jobs)
+	for i := 0; i < *retries && len(download.Failed(results)) > 0; i++ {
+		log.Printf("Retrying %d failed downloads", len(download.Failed(results)))
+		results = download.Merge(results, runner.Run(context.Background(), download.Requeue(results)))
+	}
+	if err := runner.Finish(context.Background(), results); err != nil {
+		log.Println(err)
+	}
+	download.Report(os.Stdout, results)
+	failed := download.Failed(results)
+	if len(failed) > 0 {
+		log.Fatalf("%d of %d downloads failed", len(failed), len(results))
+	}
+}
+
+// preflight warns or stops, as check says, when the jobs may not fit in the free space of dir.
+func preflight(backend download.Backend, jobs []download.Job, dir string, maxBytes int64, check string) {
+	if check == download.SpaceOff || len(jobs) == 0 {
+		return
+	}
+	p, err := backend.CheckSpace(context.Background(), jobs, dir, maxBytes)
+	if err != nil {
+		log.Printf("Cannot check the free space: %s", err)
+		return
+	}
+	if !p.Short() {
+		return
+	}
+	if check == download.SpaceRefuse {
+		log.Fatalf("Not enough space in %s: %s", dir, p)
+	}
+	log.Printf("Warning, %s may run out of space: %s", dir, p)
+}
+
+// Formats runs the formats subcommand: it lists the formats the backend finds for the episode at -url.
+func Formats(cfg config.Config, args []string) {
+	fs := flag.NewFlagSet("formats", flag.ExitOnError)
+	url := fs.String("url", "", "-url=[iPlayer episode URL]")
+	backend := download.Backend{Args: cfg.Downloader.Args}
+	fs.StringVar(&backend.Program, "backend", cfg.Downloader.Backend, "-backend=[youtube-dl or yt-dlp]")
+	httpOpts := HTTPFlags(fs, cfg.HTTP)
+	fs.Parse(args)
+	backend.SetHTTP(*httpOpts)
+	if *url == "" {
+		log.Fatal("usage: ./iplayer formats -url=[iPlayer episode URL]")
+	}
+	cmd := backend.ListFormats(context.Background(), *url)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
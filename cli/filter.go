@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"flag"
+
+	"github.com/gandalf15/iplayerlinks/filter"
+)
+
+// FilterFlags defines the episode filter flags on fs.
+// The returned spec is filled in when fs is parsed.
+func FilterFlags(fs *flag.FlagSet) *filter.Spec {
+	spec := &filter.Spec{}
+	fs.StringVar(&spec.Series, "series", "", "-series=[text in the series name]")
+	fs.StringVar(&spec.Label, "label", "", "-label=[text in the episode label]")
+	fs.StringVar(&spec.LabelRegex, "labelRegex", "", "-labelRegex=[regular expression matching the episode label]")
+	fs.StringVar(&spec.Episodes, "episodes", "", "-episodes=[S2E3-S2E8,S3,E1-E4]")
+	fs.StringVar(&spec.Variants, "variants", "", "-variants=[standard,ad,sign]")
+	fs.StringVar(&spec.From, "from", "", "-from=[2006-01-02] first air date")
+	fs.StringVar(&spec.To, "to", "", "-to=[2006-01-02] last air date")
+	return spec
+}
+
+// overrideSpec returns base with the fields of flags whose flag is in set.
+func overrideSpec(base filter.Spec, flags filter.Spec, set map[string]bool) filter.Spec {
+	fields := map[string]struct {
+		dst *string
+		src string
+	}{
+		"series":     {&base.Series, flags.Series},
+		"label":      {&base.Label, flags.Label},
+		"labelRegex": {&base.LabelRegex, flags.LabelRegex},
+		"episodes":   {&base.Episodes, flags.Episodes},
+		"variants":   {&base.Variants, flags.Variants},
+		"from":       {&base.From, flags.From},
+		"to":         {&base.To, flags.To},
+	}
+	for name, f := range fields {
+		if set[name] {
+			*f.dst = f.src
+		}
+	}
+	return base
+}
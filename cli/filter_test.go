@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/gandalf15/iplayerlinks/filter"
+)
+
+func TestOverrideSpec(t *testing.T) {
+	sub := filter.Spec{Series: "Series 1", Episodes: "S1E1-S1E4", Variants: "standard"}
+	tests := []struct {
+		name string
+		args []string
+		want filter.Spec
+	}{
+		{name: "no flags", want: sub},
+		{
+			name: "flags win",
+			args: []string{"-series=Series 2", "-from=2021-01-01"},
+			want: filter.Spec{Series: "Series 2", Episodes: "S1E1-S1E4", Variants: "standard", From: "2021-01-01"},
+		},
+		{
+			name: "empty flag clears",
+			args: []string{"-episodes="},
+			want: filter.Spec{Series: "Series 1", Variants: "standard"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			spec := FilterFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			set := map[string]bool{}
+			fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+			if got := overrideSpec(sub, *spec, set); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
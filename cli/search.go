@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/config"
+	"github.com/gandalf15/iplayerlinks/epinfo"
+	"github.com/gandalf15/iplayerlinks/ipurl"
+)
+
+// refuseSearch stops on the URL of search results, a show is picked from them with the search subcommand.
+func refuseSearch(rawURL string) {
+	if u, err := ipurl.Parse(rawURL); err == nil && u.Kind == ipurl.Search {
+		log.Fatalf("%s is a search, pick a show with: ./iplayer search %s", rawURL, u.Query)
+	}
+}
+
+// Search runs the search subcommand with its arguments:
+// it lists the iPlayer search results for the query and prints the links of the picked show.
+// Results and the prompt go to stderr so only the links are written to stdout.
+// The variants and the fetch cap default to the config.
+func Search(cfg config.Config, args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	audioDescribed := fs.Bool("audioDescribed", cfg.Variant("ad"), "-audioDescribed=[bool]")
+	signLang := fs.Bool("signLang", cfg.Variant("sign"), "-signLang=[bool]")
+	pick := fs.Int("pick", 0, "-pick=[number of the result] instead of asking")
+	spec := FilterFlags(fs)
+	httpOpts := HTTPFlags(fs, cfg.HTTP)
+	fs.Parse(args)
+	useHTTP(*httpOpts)
+	query := strings.Join(fs.Args(), " ")
+	if query == "" {
+		log.Fatal("usage: ./iplayer search [-pick=N] [query]")
+	}
+	shows, err := epinfo.Search(query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(shows) == 0 {
+		log.Fatalf("Nothing found for %q", query)
+	}
+	for i, show := range shows {
+		fmt.Fprintf(os.Stderr, "%d. %s\n   %s\n", i+1, show.Title, show.URL)
+	}
+	n := *pick
+	if n == 0 {
+		fmt.Fprintf(os.Stderr, "Pick a show [1-%d]: ", len(shows))
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatal(err)
+		}
+		n, err = strconv.Atoi(strings.TrimSpace(line))
+		if err != nil {
+			log.Fatalf("Not a number: %q", strings.TrimSpace(line))
+		}
+	}
+	if n < 1 || n > len(shows) {
+		log.Fatalf("Pick a number between 1 and %d", len(shows))
+	}
+	Cli(Options{URL: shows[n-1].URL, AudioDescribed: *audioDescribed, SignLang: *signLang,
+		MaxFetches: cfg.RateLimit.MaxFetches, Filter: *spec, HTTP: *httpOpts})
+}
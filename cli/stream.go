@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/gandalf15/iplayerlinks/epinfo"
+	"github.com/gandalf15/iplayerlinks/filter"
+)
+
+// stream prints each link as soon as its page is parsed, in the order the links are found.
+// Listings get no "# title" lines as their shows are scraped at the same time.
+func stream(opts Options, f *filter.Filter) {
+	for ev := range epinfo.Stream(context.Background(), opts.URL, opts.AudioDescribed, opts.SignLang) {
+		switch ev.Kind {
+		case epinfo.PageFetched:
+			if opts.Verbose {
+				log.Printf("Fetched %s", ev.URL)
+			}
+		case epinfo.SeriesFound:
+			if opts.Verbose {
+				log.Printf("Series %q: %s", ev.Series, ev.URL)
+			}
+		case epinfo.EpisodeFound:
+			if f.Match(ev.Episode) {
+				fmt.Println(ev.Episode.URL)
+			}
+		case epinfo.Done:
+			if ev.Err != nil {
+				log.Fatal(ev.Err)
+			}
+		}
+	}
+}
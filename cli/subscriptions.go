@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/config"
+	"github.com/gandalf15/iplayerlinks/epinfo"
+)
+
+// Subscriptions runs the subscriptions subcommand: it prints the links of every subscription
+// in the config, each subscription under a "# name" line, filtered by its own filter.
+func Subscriptions(cfg config.Config, args []string) {
+	fs := flag.NewFlagSet("subscriptions", flag.ExitOnError)
+	audioDescribed := fs.Bool("audioDescribed", cfg.Variant("ad"), "-audioDescribed=[bool]")
+	signLang := fs.Bool("signLang", cfg.Variant("sign"), "-signLang=[bool]")
+	maxFetches := fs.Int("maxFetches", cfg.RateLimit.MaxFetches,
+		"-maxFetches=[int] pages fetched at the same time, 0 for no limit")
+	httpOpts := HTTPFlags(fs, cfg.HTTP)
+	fs.Parse(args)
+	useHTTP(*httpOpts)
+	if len(cfg.Subscriptions) == 0 {
+		path, _ := config.Path()
+		log.Fatalf("No subscriptions in %s", path)
+	}
+	epinfo.SetMaxConcurrentFetches(*maxFetches)
+	var lines []string
+	for _, sub := range cfg.Subscriptions {
+		name := sub.Name
+		if name == "" {
+			name = sub.URL
+		}
+		f, err := sub.Filter.Compile()
+		if err != nil {
+			log.Fatalf("%s: %s", name, err)
+		}
+		allSeries, _, err := epinfo.AllEpisodesInfoWithDiagnostics(sub.URL, *audioDescribed, *signLang)
+		if err != nil {
+			log.Printf("%s: %s", name, err)
+		}
+		lines = append(lines, "# "+name)
+		for _, v := range f.Apply(allSeries) {
+			for _, epi := range v {
+				lines = append(lines, epi.URL)
+			}
+		}
+	}
+	fmt.Print(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,188 @@
+// Package config reads the settings shared by the command line and the GUI.
+//
+// The config file is config.yaml in the iplayerlinks directory of the user config dir,
+// $XDG_CONFIG_HOME/iplayerlinks/config.yaml on Linux. IPLAYERLINKS_CONFIG names another file.
+// Environment variables override the file, command line flags override both.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gandalf15/iplayerlinks/download"
+	"github.com/gandalf15/iplayerlinks/epinfo"
+	"github.com/gandalf15/iplayerlinks/filter"
+	"github.com/gandalf15/iplayerlinks/subtitles"
+	"gopkg.in/yaml.v2"
+)
+
+// Config holds the settings.
+// Variants lists the extra variants to get besides the standard episodes, "ad" and "sign".
+// CacheDir is where pages that no extractor recognises are saved.
+// HTTP sets up the client fetching the pages, its proxy is passed to the downloader too.
+type Config struct {
+	Variants      []string             `yaml:"variants"`
+	Downloader    Downloader           `yaml:"downloader"`
+	Subtitles     download.Subtitles   `yaml:"subtitles"`
+	Audio         download.Audio       `yaml:"audio"`
+	Hooks         download.Hooks       `yaml:"hooks"`
+	RateLimit     RateLimit            `yaml:"rate_limit"`
+	HTTP          epinfo.ClientOptions `yaml:"http"`
+	CacheDir      string               `yaml:"cache_dir"`
+	Subscriptions []Subscription       `yaml:"subscriptions"`
+}
+
+// Downloader is the program the episodes are handed to.
+type Downloader struct {
+	// Backend is the program, youtube-dl or yt-dlp.
+	Backend string `yaml:"backend"`
+	// Quality is a profile of download.ParseQuality.
+	Quality string `yaml:"quality"`
+	// Format is a format selector of the backend, it overrides Quality unless another quality
+	// is chosen with -quality or in the GUI.
+	Format string `yaml:"format"`
+	// Output is the filename template, see download.Template.
+	Output string `yaml:"output"`
+	// Args are passed to the backend before the links.
+	Args []string `yaml:"args"`
+	// Metadata writes NFO files and artwork next to the downloaded episodes for media servers.
+	Metadata bool `yaml:"metadata"`
+	// Verify checks the downloaded files, Output must then be resolvable, see download.Template.Resolvable.
+	Verify bool `yaml:"verify"`
+	// Retries is how often the failed episodes are downloaded again.
+	Retries int `yaml:"retries"`
+	// SpaceCheck is one of download.SpaceChecks.
+	SpaceCheck string `yaml:"space_check"`
+	// MaxSize caps the size of a download like "50G".
+	MaxSize string `yaml:"max_size"`
+	// Windows are the times of day like "01:00-06:00" downloads run in, see download.Schedule.
+	Windows []string `yaml:"windows"`
+}
+
+// RateLimit caps the load on iPlayer, MaxFetches 0 means no cap.
+// Bandwidth like "2M" caps the bytes per second of all downloads together, shared by at most
+// MaxDownloads running at the same time, see download.SetBandwidth.
+type RateLimit struct {
+	MaxFetches   int    `yaml:"max_fetches"`
+	Bandwidth    string `yaml:"bandwidth"`
+	MaxDownloads int    `yaml:"max_downloads"`
+}
+
+// Subscription is a show whose new episodes are fetched regularly.
+// MaxSize caps the size of its downloads instead of the one of the downloader.
+type Subscription struct {
+	Name    string      `yaml:"name"`
+	URL     string      `yaml:"url"`
+	Filter  filter.Spec `yaml:"filter"`
+	MaxSize string      `yaml:"max_size"`
+}
+
+// Default returns the settings used when there is no config file.
+func Default() Config {
+	return Config{
+		Downloader: Downloader{Backend: "youtube-dl", Quality: download.Best, Output: download.DefaultTemplate,
+			SpaceCheck: download.SpaceWarn},
+		Subtitles: download.Subtitles{Languages: []string{"en"}, Format: subtitles.SRT},
+		Audio:     download.Audio{Format: download.M4A},
+		RateLimit: RateLimit{MaxFetches: 8},
+		HTTP:      epinfo.ClientOptions{Timeout: time.Minute},
+		CacheDir:  filepath.Join(os.TempDir(), "iplayerlinks"),
+	}
+}
+
+// Path returns the config file to read.
+func Path() (string, error) {
+	if p := os.Getenv("IPLAYERLINKS_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "iplayerlinks", "config.yaml"), nil
+}
+
+// Load reads the config file over the defaults and applies the environment.
+// A missing file is not an error.
+func Load() (Config, error) {
+	cfg := Default()
+	if dir, err := os.UserCacheDir(); err == nil {
+		cfg.CacheDir = filepath.Join(dir, "iplayerlinks")
+	}
+	p, err := Path()
+	if err != nil {
+		return cfg, err
+	}
+	raw, err := ioutil.ReadFile(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cfg, err
+	}
+	if err == nil {
+		if err := yaml.UnmarshalStrict(raw, &cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return cfg, cfg.applyEnv(os.Getenv)
+}
+
+// applyEnv overrides the settings with the IPLAYERLINKS_* variables that are set.
+func (cfg *Config) applyEnv(getenv func(string) string) error {
+	if v := getenv("IPLAYERLINKS_VARIANTS"); v != "" {
+		cfg.Variants = strings.Split(v, ",")
+	}
+	if v := getenv("IPLAYERLINKS_BACKEND"); v != "" {
+		cfg.Downloader.Backend = v
+	}
+	if v := getenv("IPLAYERLINKS_QUALITY"); v != "" {
+		cfg.Downloader.Quality = v
+	}
+	if v := getenv("IPLAYERLINKS_FORMAT"); v != "" {
+		cfg.Downloader.Format = v
+	}
+	if v := getenv("IPLAYERLINKS_OUTPUT"); v != "" {
+		cfg.Downloader.Output = v
+	}
+	if v := getenv("IPLAYERLINKS_CACHE_DIR"); v != "" {
+		cfg.CacheDir = v
+	}
+	if v := getenv("IPLAYERLINKS_PROXY"); v != "" {
+		cfg.HTTP.Proxy = v
+	}
+	if v := getenv("IPLAYERLINKS_BANDWIDTH"); v != "" {
+		cfg.RateLimit.Bandwidth = v
+	}
+	if v := getenv("IPLAYERLINKS_MAX_FETCHES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("IPLAYERLINKS_MAX_FETCHES: %w", err)
+		}
+		cfg.RateLimit.MaxFetches = n
+	}
+	return nil
+}
+
+// Subscription returns the subscription called name.
+func (cfg Config) Subscription(name string) (Subscription, error) {
+	for _, sub := range cfg.Subscriptions {
+		if sub.Name == name {
+			return sub, nil
+		}
+	}
+	return Subscription{}, fmt.Errorf("no subscription %q in the config", name)
+}
+
+// Variant reports whether name, "ad" or "sign", is one of the configured variants.
+func (cfg Config) Variant(name string) bool {
+	for _, v := range cfg.Variants {
+		if strings.TrimSpace(v) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gandalf15/iplayerlinks/download"
+)
+
+// setenv sets the environment variables for the test and restores them at its end.
+func setenv(t *testing.T, vars map[string]string) {
+	t.Helper()
+	for key, value := range vars {
+		old, ok := os.LookupEnv(key)
+		os.Setenv(key, value)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+// configHome returns a config dir for the test with config.yaml holding text, none when it is empty.
+func configHome(t *testing.T, text string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if text != "" {
+		if err := os.MkdirAll(filepath.Join(dir, "iplayerlinks"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "iplayerlinks", "config.yaml"), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	file := `downloader:
+  backend: yt-dlp
+  quality: 720p
+  output: "{show}/{title}.{ext}"
+rate_limit:
+  max_fetches: 4
+http:
+  proxy: http://file:3128
+`
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		check   func(t *testing.T, cfg Config)
+		wantErr bool
+	}{
+		{
+			name: "defaults without a file",
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Downloader.Backend != "youtube-dl" || cfg.Downloader.Output != download.DefaultTemplate ||
+					cfg.RateLimit.MaxFetches != 8 {
+					t.Errorf("got %+v, want the defaults", cfg.Downloader)
+				}
+			},
+		},
+		{
+			name: "file over defaults",
+			file: file,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Downloader.Backend != "yt-dlp" || cfg.Downloader.Quality != "720p" ||
+					cfg.RateLimit.MaxFetches != 4 || cfg.HTTP.Proxy != "http://file:3128" {
+					t.Errorf("got %+v, want the values of the file", cfg)
+				}
+				if cfg.Downloader.SpaceCheck != download.SpaceWarn {
+					t.Errorf("space check %q, want the default where the file has none", cfg.Downloader.SpaceCheck)
+				}
+			},
+		},
+		{
+			name: "environment over file",
+			file: file,
+			env: map[string]string{"IPLAYERLINKS_QUALITY": "sd", "IPLAYERLINKS_PROXY": "http://env:3128",
+				"IPLAYERLINKS_MAX_FETCHES": "2", "IPLAYERLINKS_VARIANTS": "ad,sign"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Downloader.Quality != "sd" || cfg.HTTP.Proxy != "http://env:3128" || cfg.RateLimit.MaxFetches != 2 {
+					t.Errorf("got %+v, want the values of the environment", cfg)
+				}
+				if cfg.Downloader.Backend != "yt-dlp" {
+					t.Errorf("backend %q, want the one of the file", cfg.Downloader.Backend)
+				}
+				if !cfg.Variant("ad") || !cfg.Variant("sign") {
+					t.Errorf("variants %q, want ad and sign", cfg.Variants)
+				}
+			},
+		},
+		{name: "unknown key", file: "downloader:\n  backnd: yt-dlp\n", wantErr: true},
+		{name: "invalid number in the environment", env: map[string]string{"IPLAYERLINKS_MAX_FETCHES": "many"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Empty variables count as unset, the ones of whoever runs the test are left out.
+			env := map[string]string{"XDG_CONFIG_HOME": configHome(t, tt.file)}
+			for _, key := range []string{"CONFIG", "VARIANTS", "BACKEND", "QUALITY", "FORMAT", "OUTPUT",
+				"CACHE_DIR", "PROXY", "BANDWIDTH", "MAX_FETCHES"} {
+				env["IPLAYERLINKS_"+key] = ""
+			}
+			for key, value := range tt.env {
+				env[key] = value
+			}
+			setenv(t, env)
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, cfg)
+			}
+		})
+	}
+}
+
+func TestPathFromEnvironment(t *testing.T) {
+	setenv(t, map[string]string{"IPLAYERLINKS_CONFIG": "/elsewhere/config.yaml"})
+	if p, err := Path(); err != nil || p != "/elsewhere/config.yaml" {
+		t.Errorf("Path() = %q, %v, want the file of IPLAYERLINKS_CONFIG", p, err)
+	}
+}
@@ -0,0 +1,133 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/epinfo"
+)
+
+// Audio formats
+const (
+	M4A  = "m4a"
+	MP3  = "mp3"
+	Opus = "opus"
+)
+
+// AudioFormats lists the audio formats in the order they are offered.
+var AudioFormats = []string{M4A, MP3, Opus}
+
+// AlbumTemplate saves the episodes of a series as the tracks of an album.
+const AlbumTemplate = "{show}/{series}/{en:02} - {title}.{ext}"
+
+// Audio extracts the audio of the episodes to Format, one of AudioFormats, and tags it
+// with the show as artist, the series as album, the episode as title and its number as track.
+// Album lays the files out with AlbumTemplate. The backend needs ffmpeg to extract the audio.
+type Audio struct {
+	Enabled bool   `yaml:"enabled"`
+	Format  string `yaml:"format"`
+	Album   bool   `yaml:"album"`
+}
+
+// Check reports a format that is not one of AudioFormats.
+func (a Audio) Check() error {
+	for _, f := range AudioFormats {
+		if a.Format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown audio format %q, use one of %s", a.Format, strings.Join(AudioFormats, ", "))
+}
+
+// Template returns AlbumTemplate for the album layout and tmpl otherwise.
+func (a Audio) Template(tmpl *Template) (*Template, error) {
+	if a.Enabled && a.Album {
+		return ParseTemplate(AlbumTemplate)
+	}
+	return tmpl, nil
+}
+
+// Quality returns the audio only profile when the audio is extracted and q otherwise.
+func (a Audio) Quality(q Quality) Quality {
+	if a.Enabled {
+		return Quality{Name: AudioOnly}
+	}
+	return q
+}
+
+// args returns the backend arguments extracting the audio.
+func (a Audio) args() []string {
+	if !a.Enabled {
+		return nil
+	}
+	return []string{"-x", "--audio-format", a.Format}
+}
+
+// audioTags returns the tags of ep, unknown ones are left out.
+func audioTags(ep epinfo.EpisodeInfo) map[string]string {
+	tags := map[string]string{"title": ep.Label}
+	if ep.TvShow != nil {
+		tags["artist"] = *ep.TvShow
+		tags["album_artist"] = *ep.TvShow
+	}
+	tags["album"] = tags["artist"]
+	if ep.Series != "none" {
+		tags["album"] = ep.Series
+	}
+	if ep.EpisodeNo > 0 {
+		tags["track"] = strconv.Itoa(ep.EpisodeNo)
+	}
+	if ep.SeriesNo > 0 {
+		tags["disc"] = strconv.Itoa(ep.SeriesNo)
+	}
+	if !ep.Aired.IsZero() {
+		tags["date"] = ep.Aired.Format("2006-01-02")
+	}
+	for key, value := range tags {
+		if strings.TrimSpace(value) == "" {
+			delete(tags, key)
+		}
+	}
+	return tags
+}
+
+// tag writes the tags of the episode of job into its audio file with ffmpeg.
+func (a Audio) tag(job Job, out io.Writer) error {
+	if !a.Enabled {
+		return nil
+	}
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		fmt.Fprintln(out, "ffmpeg not found, the audio is not tagged")
+		return nil
+	}
+	src := job.Base + "." + a.Format
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("no %s file to tag: %w", a.Format, err)
+	}
+	tags := audioTags(job.Episode)
+	// ffmpeg picks the muxer from the extension, so the temporary file keeps it.
+	tmp := job.Base + ".tagging." + a.Format
+	args := []string{"-y", "-loglevel", "error", "-i", src, "-map", "0", "-c", "copy"}
+	keys := []string{}
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-metadata", key+"="+tags[key])
+	}
+	cmd := exec.Command(ffmpeg, append(args, tmp)...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("tagging %s: %w", src, err)
+	}
+	return os.Rename(tmp, src)
+}
@@ -0,0 +1,73 @@
+package download
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gandalf15/iplayerlinks/epinfo"
+)
+
+func TestAudioTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate(DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		audio Audio
+		want  string
+	}{
+		{Audio{}, DefaultTemplate},
+		{Audio{Album: true}, DefaultTemplate},
+		{Audio{Enabled: true}, DefaultTemplate},
+		{Audio{Enabled: true, Album: true}, AlbumTemplate},
+	}
+	for _, tt := range tests {
+		got, err := tt.audio.Template(tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.audio, got, tt.want)
+		}
+	}
+}
+
+func TestAudioTags(t *testing.T) {
+	aired := episode("Show", "Series 2", "Episode 3", 2, 3)
+	aired.Aired = time.Date(2021, 3, 19, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		ep   epinfo.EpisodeInfo
+		want map[string]string
+	}{
+		{
+			name: "all known",
+			ep:   aired,
+			want: map[string]string{"title": "Episode 3", "artist": "Show", "album_artist": "Show",
+				"album": "Series 2", "track": "3", "disc": "2", "date": "2021-03-19"},
+		},
+		{
+			name: "no series",
+			ep:   episode("Show", "none", "Special", 0, 0),
+			want: map[string]string{"title": "Special", "artist": "Show", "album_artist": "Show", "album": "Show"},
+		},
+		{
+			name: "no show",
+			ep:   epinfo.EpisodeInfo{Label: "Special", Series: "none"},
+			want: map[string]string{"title": "Special"},
+		},
+		{
+			name: "empty show",
+			ep:   episode("", "Series 1", "", 1, 0),
+			want: map[string]string{"album": "Series 1", "disc": "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := audioTags(tt.ep); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
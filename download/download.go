@@ -0,0 +1,300 @@
+// Package download hands scraped episodes to a downloader program such as youtube-dl or yt-dlp,
+// one episode at a time so each of them is saved to its own path.
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/epinfo"
+)
+
+// Backend is the downloader program with its format selector, extra arguments
+// and extra environment variables like "HTTP_PROXY=...".
+type Backend struct {
+	Program string
+	Format  string
+	Args    []string
+	Env     []string
+}
+
+// Job is an episode to download, Output is the path it is saved to as the backend reads it.
+// Base is the same path on disk without the extension, ShowDir the top folder the template
+// puts the show in, or the download folder when it has none.
+type Job struct {
+	Episode epinfo.EpisodeInfo
+	Output  string
+	Base    string
+	ShowDir string
+}
+
+// Jobs returns a job for each episode, saved in dir under the name tmpl gives it.
+func Jobs(episodes []epinfo.EpisodeInfo, dir string, tmpl *Template) []Job {
+	jobs := []Job{}
+	for _, ep := range episodes {
+		rel := tmpl.Execute(ep, "{ext}")
+		job := Job{Episode: ep, Output: filepath.Join(dir, filepath.FromSlash(tmpl.Backend(ep))),
+			Base: filepath.Join(dir, filepath.FromSlash(strings.TrimSuffix(rel, ".{ext}"))), ShowDir: dir}
+		if i := strings.Index(rel, "/"); i > 0 {
+			job.ShowDir = filepath.Join(dir, rel[:i])
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// Command returns the backend command downloading the job, it is killed when ctx is cancelled.
+// extra arguments are passed after the ones of the backend.
+func (b Backend) Command(ctx context.Context, job Job, extra ...string) *exec.Cmd {
+	args := []string{}
+	if b.Format != "" {
+		args = append(args, "-f", b.Format)
+	}
+	args = append(args, b.Args...)
+	args = append(args, extra...)
+	args = append(args, "-o", job.Output, job.Episode.URL)
+	return b.command(ctx, args)
+}
+
+// command returns the backend command with args and the environment of the backend.
+func (b Backend) command(ctx context.Context, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, b.Program, args...)
+	if len(b.Env) > 0 {
+		cmd.Env = append(os.Environ(), b.Env...)
+	}
+	return cmd
+}
+
+// Result is the outcome of a job, Err is nil when the backend succeeded.
+// Skipped tells why a job was not run, like ErrStorageCap, it then has no Err and is not failed.
+// Started tells whether the backend was run for the job, the hooks only see the jobs that started.
+// Hook is the error of the episode hook, nil when it succeeded or there is none.
+type Result struct {
+	Job     Job
+	Err     error
+	Skipped error
+	Started bool
+	Hook    error
+}
+
+// Runner downloads jobs one after another.
+type Runner struct {
+	// Backend downloads the episodes.
+	Backend Backend
+	// Output gets the output of the backend, it may be nil.
+	Output io.Writer
+	// Sidecars writes the NFO files and the artwork of every downloaded episode, see WriteSidecars.
+	Sidecars bool
+	// Subtitles are downloaded with the episodes, they are not muxed into extracted Audio.
+	Subtitles Subtitles
+	// Audio keeps only the audio of the episodes when enabled.
+	Audio Audio
+	// Hooks run after every job that started and was not cancelled, see Finish for the batch hook.
+	Hooks Hooks
+	// Verify checks the files of every downloaded episode, see Verify, a job whose files fail it fails.
+	Verify bool
+	// MaxBytes above 0 caps the size of the downloads: the queue stops before a job that would go
+	// over it going by the average size so far, the jobs left are skipped.
+	MaxBytes int64
+	// Used counts the bytes the jobs added to the download folder.
+	Used int64
+	// Schedule is the windows the backend runs in.
+	Schedule Schedule
+	// done counts the jobs run for the average size.
+	done int
+}
+
+func (r *Runner) output() io.Writer {
+	if r.Output == nil {
+		return ioutil.Discard
+	}
+	return r.Output
+}
+
+// Run downloads the jobs one at a time and returns their results in the same order.
+// Once ctx is cancelled the running job is killed and the remaining ones fail with the error of ctx.
+// As the jobs of a Runner never overlap, the bandwidth limit of SetBandwidth only matters
+// between Runners running at the same time, like two batches of the GUI.
+func (r *Runner) Run(ctx context.Context, jobs []Job) []Result {
+	results := []Result{}
+	for _, job := range jobs {
+		res := Result{Job: job}
+		if res.Err = ctx.Err(); res.Err == nil && r.capped() {
+			res.Skipped = fmt.Errorf("%w: %s of %s used", ErrStorageCap, FormatSize(r.Used), FormatSize(r.MaxBytes))
+			results = append(results, res)
+			continue
+		}
+		if res.Err == nil {
+			// Files there before the job, like a partial download it continues, do not count.
+			before := filesSize(job)
+			res.Started, res.Err = r.run(ctx, job)
+			if added := filesSize(job) - before; added > 0 {
+				r.Used += added
+			}
+			r.done++
+		}
+		if res.Started && ctx.Err() == nil {
+			res.Hook = r.Hooks.episode(ctx, res, r.output())
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// capped reports whether the next job would go over MaxBytes.
+func (r *Runner) capped() bool {
+	if r.MaxBytes <= 0 {
+		return false
+	}
+	next := int64(0)
+	if r.done > 0 {
+		next = r.Used / int64(r.done)
+	}
+	return r.Used+next > r.MaxBytes
+}
+
+// Finish runs the batch hook over the results of Run unless ctx is cancelled.
+func (r *Runner) Finish(ctx context.Context, results []Result) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return r.Hooks.batch(ctx, results, r.output())
+}
+
+// run downloads job and reports whether the backend was started.
+func (r *Runner) run(ctx context.Context, job Job) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(job.Output), 0755); err != nil {
+		return false, err
+	}
+	out := r.output()
+	if started, err := r.download(ctx, job, out); err != nil {
+		return started, err
+	}
+	subs := r.Subtitles
+	subs.Mux = subs.Mux && !r.Audio.Enabled
+	if err := subs.process(job, out); err != nil {
+		// The episode is there, subtitles that cannot be converted or muxed are only reported.
+		fmt.Fprintf(out, "Failed to process subtitles of %s: %s\n", job.Episode.URL, err)
+	}
+	if err := r.Audio.tag(job, out); err != nil {
+		return true, fmt.Errorf("audio of %s: %w", job.Episode.URL, err)
+	}
+	if r.Verify {
+		if err := Verify(job, r.Subtitles); err != nil {
+			return true, err
+		}
+	}
+	if r.Sidecars {
+		// The episode is there, missing metadata is only reported.
+		if err := WriteSidecars(ctx, job); err != nil {
+			fmt.Fprintf(out, "Failed to write metadata of %s: %s\n", job.Episode.URL, err)
+		}
+	}
+	return true, nil
+}
+
+// download runs the backend on job in the windows of the Schedule. It is stopped when a window closes
+// and run again when the next one opens, the backend then continues the partial download.
+// started tells whether the backend was run at all.
+func (r *Runner) download(ctx context.Context, job Job, out io.Writer) (started bool, err error) {
+	for {
+		end, err := r.Schedule.wait(ctx, job, out)
+		if err != nil {
+			return started, err
+		}
+		rate, release, err := acquireSlot(ctx)
+		if err != nil {
+			return started, err
+		}
+		windowCtx, cancel := ctx, context.CancelFunc(func() {})
+		if !end.IsZero() {
+			windowCtx, cancel = context.WithDeadline(ctx, end)
+		}
+		args := append(append(r.Subtitles.args(), r.Audio.args()...), rate...)
+		cmd := r.Backend.Command(windowCtx, job, args...)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		fmt.Fprintf(out, "Downloading %s\n", job.Episode.URL)
+		started = true
+		err = runGroup(windowCtx, cmd)
+		paused := err != nil && windowCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		release()
+		switch {
+		case ctx.Err() != nil:
+			return started, ctx.Err()
+		case paused:
+			fmt.Fprintf(out, "Paused %s until the next download window\n", job.Episode.URL)
+			continue
+		case err != nil:
+			return started, fmt.Errorf("%s %s: %w", r.Backend.Program, job.Episode.URL, err)
+		}
+		return started, nil
+	}
+}
+
+// Failed returns the results that have an error.
+func Failed(results []Result) []Result {
+	failed := []Result{}
+	for _, res := range results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Requeue returns the jobs of the results that failed, to run them again.
+func Requeue(results []Result) []Job {
+	jobs := []Job{}
+	for _, res := range Failed(results) {
+		jobs = append(jobs, res.Job)
+	}
+	return jobs
+}
+
+// Merge returns results with the results of jobs run again replacing theirs.
+// A job stays started when an earlier run of it started, a job skipped again keeps its earlier result.
+func Merge(results []Result, again []Result) []Result {
+	byOutput := map[string]Result{}
+	for _, res := range again {
+		byOutput[res.Job.Output] = res
+	}
+	merged := []Result{}
+	for _, res := range results {
+		if newer, ok := byOutput[res.Job.Output]; ok && newer.Skipped == nil {
+			newer.Started = newer.Started || res.Started
+			res = newer
+		}
+		merged = append(merged, res)
+	}
+	return merged
+}
+
+// Report writes a line per result telling whether the episode passed, failed or was skipped,
+// with the errors of the ones that did not pass and of their hooks.
+func Report(w io.Writer, results []Result) {
+	for _, res := range results {
+		status, err := "PASS", res.Err
+		switch {
+		case res.Err != nil:
+			status = "FAIL"
+		case res.Skipped != nil:
+			status, err = "SKIPPED", res.Skipped
+		}
+		fmt.Fprintf(w, "%s %s", status, res.Job.Episode.Label)
+		if err != nil {
+			fmt.Fprintf(w, ": %s", err)
+		}
+		if res.Hook != nil {
+			fmt.Fprintf(w, " (%s)", res.Hook)
+		}
+		fmt.Fprintln(w)
+	}
+}
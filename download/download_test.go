@@ -0,0 +1,113 @@
+package download
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// result returns the result of a job writing to output.
+func result(output string, err error, started bool) Result {
+	return Result{Job: Job{Output: output}, Err: err, Started: started}
+}
+
+// outputs returns the outputs of the jobs.
+func outputs(jobs []Job) []string {
+	found := []string{}
+	for _, job := range jobs {
+		found = append(found, job.Output)
+	}
+	return found
+}
+
+func TestRequeue(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name    string
+		results []Result
+		want    []string
+	}{
+		{name: "none", results: nil, want: []string{}},
+		{name: "all passed", results: []Result{result("a", nil, true), result("b", nil, true)}, want: []string{}},
+		{
+			name:    "some failed",
+			results: []Result{result("a", failed, true), result("b", nil, true), result("c", failed, false)},
+			want:    []string{"a", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outputs(Requeue(tt.results)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Requeue = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name    string
+		results []Result
+		again   []Result
+		want    []Result
+	}{
+		{
+			name:    "nothing again",
+			results: []Result{result("a", nil, true), result("b", failed, true)},
+			want:    []Result{result("a", nil, true), result("b", failed, true)},
+		},
+		{
+			name:    "retry passed",
+			results: []Result{result("a", failed, true), result("b", nil, true), result("c", failed, true)},
+			again:   []Result{result("c", nil, true), result("a", nil, true)},
+			want:    []Result{result("a", nil, true), result("b", nil, true), result("c", nil, true)},
+		},
+		{
+			name:    "retry failed again",
+			results: []Result{result("a", failed, true)},
+			again:   []Result{result("a", ErrVerify, true)},
+			want:    []Result{result("a", ErrVerify, true)},
+		},
+		{
+			name:    "retry never started",
+			results: []Result{result("a", failed, true), result("b", failed, false)},
+			again:   []Result{result("a", failed, false), result("b", failed, false)},
+			want:    []Result{result("a", failed, true), result("b", failed, false)},
+		},
+		{
+			name:    "retry skipped",
+			results: []Result{result("a", failed, true)},
+			again:   []Result{{Job: Job{Output: "a"}, Skipped: ErrStorageCap}},
+			want:    []Result{result("a", failed, true)},
+		},
+		{
+			name:    "unknown job",
+			results: []Result{result("a", failed, true)},
+			again:   []Result{result("z", nil, true)},
+			want:    []Result{result("a", failed, true)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Merge(tt.results, tt.again); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	results := []Result{
+		{Job: Job{Episode: episode("Show", "", "Ep 1", 0, 1)}},
+		{Job: Job{Episode: episode("Show", "", "Ep 2", 0, 2)}, Err: errors.New("exit status 1")},
+		{Job: Job{Episode: episode("Show", "", "Ep 3", 0, 3)}, Skipped: ErrStorageCap},
+	}
+	var out strings.Builder
+	Report(&out, results)
+	want := "PASS Ep 1\nFAIL Ep 2: exit status 1\nSKIPPED Ep 3: storage cap reached\n"
+	if out.String() != want {
+		t.Errorf("report = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,128 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Hooks are shell commands run after the downloads, to transcode, move the files or rescan a library.
+// Episode runs after every episode, Batch once after all of them. Jobs that never started, because the
+// downloads were cancelled or the backend could not be run, are left out of both.
+// The episode hook gets the template fields of the episode as IPLAYERLINKS_SHOW, IPLAYERLINKS_SERIES and so on,
+// with IPLAYERLINKS_URL, IPLAYERLINKS_FILE, IPLAYERLINKS_STATUS ("ok" or "failed") and IPLAYERLINKS_ERROR,
+// and the same values as a JSON object on stdin.
+// The batch hook gets IPLAYERLINKS_TOTAL and IPLAYERLINKS_FAILED and a JSON array of the episodes on stdin.
+type Hooks struct {
+	Episode string `yaml:"episode"`
+	Batch   string `yaml:"batch"`
+}
+
+// hookEpisode is what a hook is told about an episode.
+type hookEpisode struct {
+	Show    string `json:"show"`
+	Series  string `json:"series"`
+	Title   string `json:"title"`
+	SN      int    `json:"sn"`
+	EN      int    `json:"en"`
+	Variant string `json:"variant"`
+	Date    string `json:"date"`
+	PID     string `json:"pid"`
+	URL     string `json:"url"`
+	File    string `json:"file"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+func newHookEpisode(res Result) hookEpisode {
+	ep := res.Job.Episode
+	h := hookEpisode{Show: fields["show"](ep), Series: fields["series"](ep), Title: ep.Label,
+		SN: ep.SeriesNo, EN: ep.EpisodeNo, Variant: ep.Variant(), Date: fields["date"](ep), PID: fields["pid"](ep),
+		URL: ep.URL, File: video(res.Job), Status: "ok"}
+	if res.Err != nil {
+		h.Status = "failed"
+		h.Error = res.Err.Error()
+	}
+	return h
+}
+
+// env returns the values of h as environment variables.
+func (h hookEpisode) env() []string {
+	values := map[string]string{"SHOW": h.Show, "SERIES": h.Series, "TITLE": h.Title,
+		"SN": strconv.Itoa(h.SN), "EN": strconv.Itoa(h.EN), "VARIANT": h.Variant, "DATE": h.Date, "PID": h.PID,
+		"URL": h.URL, "FILE": h.File, "STATUS": h.Status, "ERROR": h.Error}
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	env := []string{}
+	for _, key := range keys {
+		env = append(env, "IPLAYERLINKS_"+key+"="+values[key])
+	}
+	return env
+}
+
+// shell returns the command running line in the shell of the system.
+func shell(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// runHook runs the hook line with the extra environment and input on stdin.
+// The error tells the exit status of a hook that failed.
+func runHook(ctx context.Context, line string, env []string, input interface{}, out io.Writer) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	cmd := shell(ctx, line)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hook %q: %w", line, err)
+	}
+	return nil
+}
+
+// episode runs the episode hook on the result of a job.
+func (h Hooks) episode(ctx context.Context, res Result, out io.Writer) error {
+	if strings.TrimSpace(h.Episode) == "" {
+		return nil
+	}
+	ep := newHookEpisode(res)
+	return runHook(ctx, h.Episode, ep.env(), ep, out)
+}
+
+// batch runs the batch hook on the results of the jobs that started, it is not run when none did.
+func (h Hooks) batch(ctx context.Context, results []Result, out io.Writer) error {
+	if strings.TrimSpace(h.Batch) == "" {
+		return nil
+	}
+	started := []Result{}
+	episodes := []hookEpisode{}
+	for _, res := range results {
+		if res.Started {
+			started = append(started, res)
+			episodes = append(episodes, newHookEpisode(res))
+		}
+	}
+	if len(started) == 0 {
+		return nil
+	}
+	env := []string{"IPLAYERLINKS_TOTAL=" + strconv.Itoa(len(started)),
+		"IPLAYERLINKS_FAILED=" + strconv.Itoa(len(Failed(started)))}
+	return runHook(ctx, h.Batch, env, episodes, out)
+}
@@ -0,0 +1,42 @@
+package download
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestHooksSkipJobsNotStarted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of the test need sh")
+	}
+	program, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("no true command to stand in for the backend")
+	}
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The folder of the job cannot be made under a file, so the backend never starts.
+	touch(t, dir, "file")
+	job := Job{Output: filepath.Join(dir, "file", "Ep.%(ext)s"), Base: filepath.Join(dir, "file", "Ep")}
+	marker := filepath.Join(dir, "ran")
+	r := &Runner{Backend: Backend{Program: program}, Output: ioutil.Discard,
+		Hooks: Hooks{Episode: "touch " + marker, Batch: "touch " + marker}}
+	results := r.Run(context.Background(), []Job{job})
+	if results[0].Err == nil || results[0].Started {
+		t.Fatalf("got error %v, started %v, want a job that never started", results[0].Err, results[0].Started)
+	}
+	if err := r.Finish(context.Background(), results); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("hook run for a job that never started")
+	}
+}
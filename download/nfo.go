@@ -0,0 +1,122 @@
+package download
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gandalf15/iplayerlinks/epinfo"
+	"github.com/gandalf15/iplayerlinks/ipurl"
+)
+
+// uniqueID is the BBC programme id of a show or an episode.
+type uniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	ID      string `xml:",chardata"`
+}
+
+// tvShowNFO is the tvshow.nfo read by Kodi, Jellyfin and Plex agents.
+type tvShowNFO struct {
+	XMLName  xml.Name  `xml:"tvshow"`
+	Title    string    `xml:"title"`
+	Plot     string    `xml:"plot,omitempty"`
+	UniqueID *uniqueID `xml:"uniqueid,omitempty"`
+}
+
+// episodeNFO is the .nfo next to an episode.
+type episodeNFO struct {
+	XMLName   xml.Name  `xml:"episodedetails"`
+	Title     string    `xml:"title"`
+	ShowTitle string    `xml:"showtitle,omitempty"`
+	Season    int       `xml:"season,omitempty"`
+	Episode   int       `xml:"episode,omitempty"`
+	Plot      string    `xml:"plot,omitempty"`
+	Aired     string    `xml:"aired,omitempty"`
+	UniqueID  *uniqueID `xml:"uniqueid,omitempty"`
+}
+
+// WriteSidecars writes the NFO file and the thumbnail of the episode of job next to it
+// and, unless ShowDir has one already, tvshow.nfo and the poster of its show.
+// The synopses and the artwork are read from the iPlayer pages until ctx is cancelled,
+// the artwork is saved with the extension of its type, like -thumb.jpg and poster.jpg.
+func WriteSidecars(ctx context.Context, job Job) error {
+	ep := job.Episode
+	m, err := epinfo.PageMetadataContext(ctx, ep.URL)
+	if err != nil {
+		return err
+	}
+	nfo := episodeNFO{Title: m.Title, Season: ep.SeriesNo, Episode: ep.EpisodeNo, Plot: m.Synopsis}
+	if nfo.Title == "" {
+		nfo.Title = ep.Label
+	}
+	if ep.TvShow != nil {
+		nfo.ShowTitle = *ep.TvShow
+	}
+	if !ep.Aired.IsZero() {
+		nfo.Aired = ep.Aired.Format("2006-01-02")
+	}
+	if u, err := ipurl.Parse(ep.URL); err == nil {
+		nfo.UniqueID = &uniqueID{Type: "bbc", Default: true, ID: u.PID}
+	}
+	if err := writeNFO(job.Base+".nfo", nfo); err != nil {
+		return err
+	}
+	if err := saveArtwork(ctx, m.Image, job.Base+"-thumb"); err != nil {
+		return err
+	}
+	return writeShowSidecars(ctx, job)
+}
+
+// writeShowSidecars writes tvshow.nfo and the poster of the show of job if they are not there yet.
+func writeShowSidecars(ctx context.Context, job Job) error {
+	showNFO := filepath.Join(job.ShowDir, "tvshow.nfo")
+	if _, err := os.Stat(showNFO); err == nil {
+		return nil
+	}
+	listURL, err := epinfo.EpisodesListURLContext(ctx, job.Episode.URL)
+	if err != nil {
+		return err
+	}
+	m, err := epinfo.PageMetadataContext(ctx, listURL)
+	if err != nil {
+		return err
+	}
+	nfo := tvShowNFO{Title: m.Title, Plot: m.Synopsis}
+	if job.Episode.TvShow != nil && *job.Episode.TvShow != "" {
+		nfo.Title = *job.Episode.TvShow
+	}
+	if u, err := ipurl.Parse(listURL); err == nil {
+		nfo.UniqueID = &uniqueID{Type: "bbc", Default: true, ID: u.PID}
+	}
+	if err := saveArtwork(ctx, m.Image, filepath.Join(job.ShowDir, "poster")); err != nil {
+		return err
+	}
+	return writeNFO(showNFO, nfo)
+}
+
+func writeNFO(path string, nfo interface{}) error {
+	raw, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append([]byte(xml.Header), append(raw, '\n')...), 0644)
+}
+
+// saveArtwork saves the image at imageURL to base with the extension of its type,
+// pages without artwork are skipped.
+func saveArtwork(ctx context.Context, imageURL string, base string) error {
+	if imageURL == "" {
+		return nil
+	}
+	raw, ext, err := epinfo.Artwork(ctx, imageURL)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(base+ext, raw, 0644)
+}
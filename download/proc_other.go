@@ -0,0 +1,14 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !illumos && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!illumos,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package download
+
+import (
+	"context"
+	"os/exec"
+)
+
+// runGroup runs cmd, which is killed with ctx. The processes it started are not known on this system.
+func runGroup(ctx context.Context, cmd *exec.Cmd) error {
+	return cmd.Run()
+}
@@ -0,0 +1,26 @@
+package download
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+)
+
+// runGroup runs cmd and kills it with the processes it started once ctx is done,
+// so the ffmpeg the backend starts does not go on after it.
+func runGroup(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+	return err
+}
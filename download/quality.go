@@ -0,0 +1,110 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/epinfo"
+)
+
+// Quality profiles
+const (
+	Best      = "best"
+	HD1080    = "1080p"
+	HD720     = "720p"
+	SD        = "sd"
+	AudioOnly = "audio"
+	Smallest  = "smallest"
+)
+
+// Qualities lists the profiles in the order they are offered, Smallest needs its size as "smallest:N".
+var Qualities = []string{Best, HD1080, HD720, SD, AudioOnly, Smallest + ":500"}
+
+// Quality is a download quality profile, MBPerHour is the size cap of Smallest.
+type Quality struct {
+	Name      string
+	MBPerHour int
+}
+
+// ParseQuality reads a profile: best, 1080p, 720p, sd, audio or smallest:N for at most N MB per hour.
+func ParseQuality(s string) (Quality, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", Best:
+		return Quality{Name: Best}, nil
+	case HD1080, HD720, SD, AudioOnly:
+		return Quality{Name: s}, nil
+	}
+	if strings.HasPrefix(s, Smallest+":") {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, Smallest+":"))
+		if err != nil || n <= 0 {
+			return Quality{}, fmt.Errorf("invalid size in quality %q, use smallest:N with N MB per hour", s)
+		}
+		return Quality{Name: Smallest, MBPerHour: n}, nil
+	}
+	return Quality{}, fmt.Errorf("unknown quality %q, use one of best, 1080p, 720p, sd, audio or smallest:N", s)
+}
+
+func (q Quality) String() string {
+	if q.Name == Smallest {
+		return fmt.Sprintf("%s:%d", Smallest, q.MBPerHour)
+	}
+	return q.Name
+}
+
+// isYtDlp reports whether program is yt-dlp, which fetches video and audio separately.
+func isYtDlp(program string) bool {
+	return strings.Contains(strings.ToLower(program), "yt-dlp")
+}
+
+// Selector returns the format selector of the profile for the backend program.
+func (q Quality) Selector(program string) string {
+	height := map[string]int{HD1080: 1080, HD720: 720, SD: 576}[q.Name]
+	switch {
+	case height > 0 && isYtDlp(program):
+		return fmt.Sprintf("bv*[height<=%d]+ba/b[height<=%d]", height, height)
+	case height > 0:
+		return fmt.Sprintf("best[height<=%d]/worst", height)
+	case q.Name == AudioOnly:
+		return "bestaudio/worst"
+	case q.Name == Smallest:
+		// tbr is in kbit/s, a MB per hour is 8000 kbit in 3600 s.
+		return fmt.Sprintf("best[tbr<=%d]/worst", q.MBPerHour*8000/3600)
+	case isYtDlp(program):
+		return "bv*+ba/b"
+	}
+	return "best"
+}
+
+// SetQuality sets the format selector of the profile unless a format is set already.
+func (b *Backend) SetQuality(q Quality) {
+	if b.Format == "" {
+		b.Format = q.Selector(b.Program)
+	}
+}
+
+// SetHTTP passes the proxy and the User-Agent of opts to the backend, which reads HTTP_PROXY
+// and HTTPS_PROXY itself without a proxy. The proxy goes in the environment so its password
+// does not show in the process list. The backends have no option for the CA file.
+func (b *Backend) SetHTTP(opts epinfo.ClientOptions) {
+	if opts.Proxy != "" {
+		env := append([]string{}, b.Env...)
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			env = append(env, name+"="+opts.Proxy)
+		}
+		b.Env = env
+	}
+	args := append([]string{}, b.Args...)
+	if opts.UserAgent != "" {
+		args = append(args, "--user-agent", opts.UserAgent)
+	}
+	b.Args = args
+}
+
+// ListFormats returns the backend command listing the formats available for the episode at url.
+func (b Backend) ListFormats(ctx context.Context, url string) *exec.Cmd {
+	return b.command(ctx, append(append([]string{}, b.Args...), "-F", url))
+}
@@ -0,0 +1,189 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// bandwidthMu guards bandwidth and downloadSlots.
+	bandwidthMu sync.Mutex
+	// bandwidth is the limit of all downloads together in bytes per second, 0 means no limit.
+	bandwidth int64
+	// downloadSlots limits the number of downloads at the same time, nil means no limit.
+	downloadSlots chan struct{}
+)
+
+// SetBandwidth caps the bytes per second of all downloads of the process together, limit <= 0 removes the cap.
+// At most parallel downloads run at the same time, one when parallel <= 0 and there is a cap,
+// and each is passed its share of limit with --limit-rate, so the cap holds however many Runners run.
+// Setting the same values again changes nothing, other values let downloads running when
+// it is called keep their share until they end.
+func SetBandwidth(limit int64, parallel int) {
+	bandwidthMu.Lock()
+	defer bandwidthMu.Unlock()
+	if limit < 0 {
+		limit = 0
+	}
+	if limit > 0 && parallel <= 0 {
+		parallel = 1
+	}
+	if limit == bandwidth && parallel == cap(downloadSlots) {
+		// Keep the slots the running downloads hold.
+		return
+	}
+	bandwidth = limit
+	if parallel <= 0 {
+		downloadSlots = nil
+	} else {
+		downloadSlots = make(chan struct{}, parallel)
+	}
+}
+
+// acquireSlot waits for a download slot and returns the arguments limiting the rate of the download
+// with the function that frees the slot.
+func acquireSlot(ctx context.Context) ([]string, func(), error) {
+	bandwidthMu.Lock()
+	slots, limit := downloadSlots, bandwidth
+	bandwidthMu.Unlock()
+	if slots == nil {
+		return nil, func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	release := func() { <-slots }
+	if limit == 0 {
+		return nil, release, nil
+	}
+	return []string{"--limit-rate", strconv.FormatInt(limit/int64(cap(slots)), 10)}, release, nil
+}
+
+// windowRe matches a window like 01:00-06:00.
+var windowRe = regexp.MustCompile(`^(\d{1,2}):(\d{2})\s*-\s*(\d{1,2}):(\d{2})$`)
+
+// Window is a time of day during which downloads run, in minutes after midnight.
+// An End before Start spans midnight.
+type Window struct {
+	Start, End int
+}
+
+// ParseWindow reads a window like 01:00-06:00 or 22:30-02:00.
+func ParseWindow(s string) (Window, error) {
+	m := windowRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Window{}, fmt.Errorf("invalid download window %q, use HH:MM-HH:MM", s)
+	}
+	n := make([]int, 4)
+	for i := range n {
+		n[i], _ = strconv.Atoi(m[i+1])
+	}
+	if n[0] > 23 || n[2] > 24 || n[1] > 59 || n[3] > 59 || (n[2] == 24 && n[3] > 0) {
+		return Window{}, fmt.Errorf("invalid time in download window %q", s)
+	}
+	w := Window{Start: n[0]*60 + n[1], End: n[2]*60 + n[3]}
+	if w.Start == w.End {
+		return Window{}, fmt.Errorf("empty download window %q", s)
+	}
+	return w, nil
+}
+
+func (w Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+// Schedule is the windows downloads run in, an empty Schedule lets them run at any time.
+type Schedule []Window
+
+// ParseSchedule reads the windows of a schedule.
+func ParseSchedule(windows []string) (Schedule, error) {
+	s := Schedule{}
+	for _, text := range windows {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		w, err := ParseWindow(text)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, w)
+	}
+	return s, nil
+}
+
+func (s Schedule) String() string {
+	windows := []string{}
+	for _, w := range s {
+		windows = append(windows, w.String())
+	}
+	return strings.Join(windows, ",")
+}
+
+// Open returns when downloads may run next after now and when they must pause again.
+// start is now inside a window, end is zero for an empty Schedule.
+// Windows that overlap or follow on, like 22:00-24:00 and 00:00-06:00, are open as one.
+func (s Schedule) Open(now time.Time) (start time.Time, end time.Time) {
+	if len(s) == 0 {
+		return now, time.Time{}
+	}
+	type span struct {
+		from, to time.Time
+	}
+	spans := []span{}
+	for _, w := range s {
+		length := w.End - w.Start
+		if length <= 0 {
+			length += 24 * 60
+		}
+		// The window of yesterday may still be open, the ones of the next days may be next or follow on.
+		for day := -1; day <= 2; day++ {
+			from := time.Date(now.Year(), now.Month(), now.Day()+day, w.Start/60, w.Start%60, 0, 0, now.Location())
+			if to := from.Add(time.Duration(length) * time.Minute); to.After(now) {
+				spans = append(spans, span{from, to})
+			}
+		}
+	}
+	for _, sp := range spans {
+		if start.IsZero() || sp.from.Before(start) {
+			start, end = sp.from, sp.to
+		}
+	}
+	for merged := true; merged; {
+		merged = false
+		for _, sp := range spans {
+			if !sp.from.After(end) && sp.to.After(end) {
+				end, merged = sp.to, true
+			}
+		}
+	}
+	if start.Before(now) {
+		start = now
+	}
+	return start, end
+}
+
+// wait blocks until the schedule is open and returns when it closes again, zero if it never does.
+// out is told when the download of job waits.
+func (s Schedule) wait(ctx context.Context, job Job, out io.Writer) (time.Time, error) {
+	start, end := s.Open(time.Now())
+	if d := time.Until(start); d > 0 {
+		fmt.Fprintf(out, "Waiting for the download window at %s for %s\n", start.Format("15:04"), job.Episode.URL)
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return time.Time{}, ctx.Err()
+		}
+		_, end = s.Open(time.Now())
+	}
+	return end, nil
+}
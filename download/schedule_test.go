@@ -0,0 +1,87 @@
+package download
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Window
+		wantErr bool
+	}{
+		{text: "01:00-06:00", want: Window{Start: 60, End: 360}},
+		{text: " 22:30 - 02:00 ", want: Window{Start: 22*60 + 30, End: 120}},
+		{text: "22:00-24:00", want: Window{Start: 22 * 60, End: 24 * 60}},
+		{text: "0:00-6:00", want: Window{Start: 0, End: 360}},
+		{text: "06:00-06:00", wantErr: true},
+		{text: "24:00-06:00", wantErr: true},
+		{text: "22:00-24:30", wantErr: true},
+		{text: "01:60-06:00", wantErr: true},
+		{text: "01:00", wantErr: true},
+		{text: "1am-6am", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseWindow(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseWindow(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleOpen(t *testing.T) {
+	// at returns the time on the day after 1 March 2021 at hh:mm.
+	at := func(day, hh, mm int) time.Time {
+		return time.Date(2021, time.March, 1+day, hh, mm, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name      string
+		windows   []string
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{name: "inside", windows: []string{"01:00-06:00"}, now: at(0, 2, 0), wantStart: at(0, 2, 0), wantEnd: at(0, 6, 0)},
+		{name: "before", windows: []string{"01:00-06:00"}, now: at(0, 0, 30), wantStart: at(0, 1, 0), wantEnd: at(0, 6, 0)},
+		{name: "after", windows: []string{"01:00-06:00"}, now: at(0, 7, 0), wantStart: at(1, 1, 0), wantEnd: at(1, 6, 0)},
+		{name: "at the end", windows: []string{"01:00-06:00"}, now: at(0, 6, 0), wantStart: at(1, 1, 0), wantEnd: at(1, 6, 0)},
+		{name: "over midnight before it", windows: []string{"22:30-02:00"}, now: at(0, 23, 0),
+			wantStart: at(0, 23, 0), wantEnd: at(1, 2, 0)},
+		{name: "over midnight after it", windows: []string{"22:30-02:00"}, now: at(0, 1, 0),
+			wantStart: at(0, 1, 0), wantEnd: at(0, 2, 0)},
+		{name: "over midnight waiting", windows: []string{"22:30-02:00"}, now: at(0, 12, 0),
+			wantStart: at(0, 22, 30), wantEnd: at(1, 2, 0)},
+		{name: "following on at midnight", windows: []string{"22:00-24:00", "00:00-06:00"}, now: at(0, 23, 0),
+			wantStart: at(0, 23, 0), wantEnd: at(1, 6, 0)},
+		{name: "following on waiting", windows: []string{"00:00-06:00", "22:00-24:00"}, now: at(0, 12, 0),
+			wantStart: at(0, 22, 0), wantEnd: at(1, 6, 0)},
+		{name: "overlapping", windows: []string{"01:00-04:00", "03:00-06:00"}, now: at(0, 2, 0),
+			wantStart: at(0, 2, 0), wantEnd: at(0, 6, 0)},
+		{name: "apart", windows: []string{"01:00-03:00", "04:00-06:00"}, now: at(0, 2, 0),
+			wantStart: at(0, 2, 0), wantEnd: at(0, 3, 0)},
+		{name: "next of two", windows: []string{"01:00-03:00", "04:00-06:00"}, now: at(0, 3, 30),
+			wantStart: at(0, 4, 0), wantEnd: at(0, 6, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.windows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start, end := s.Open(tt.now)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("Open(%s) = %s, %s, want %s, %s", tt.now, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+	now := at(0, 12, 0)
+	if start, end := (Schedule{}).Open(now); !start.Equal(now) || !end.IsZero() {
+		t.Errorf("empty schedule open at %s until %s, want now and never closing", start, end)
+	}
+}
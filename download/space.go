@@ -0,0 +1,189 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Space checks, what happens when the download folder has less free space than the episodes need
+const (
+	SpaceOff    = "off"
+	SpaceWarn   = "warn"
+	SpaceRefuse = "refuse"
+)
+
+// SpaceChecks lists the space checks in the order they are offered.
+var SpaceChecks = []string{SpaceWarn, SpaceRefuse, SpaceOff}
+
+// ErrStorageCap is why jobs are skipped once the storage cap of a Runner is reached.
+var ErrStorageCap = errors.New("storage cap reached")
+
+// sizeUnits are the multipliers of the size suffixes.
+var sizeUnits = map[string]int64{"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}
+
+// ParseSize reads a size in bytes or with a K, M, G or T suffix like 500M or 1.5T, "" is 0.
+func ParseSize(text string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(text))
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(s, "B")
+	unit := ""
+	if n := len(s); n > 0 && strings.Contains("KMGT", s[n-1:]) {
+		unit = s[n-1:]
+		s = s[:n-1]
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, use bytes or a number with K, M, G or T", text)
+	}
+	return int64(n * float64(sizeUnits[unit])), nil
+}
+
+// FormatSize writes n bytes with the largest unit that keeps it at least 1.
+func FormatSize(n int64) string {
+	for _, unit := range []string{"T", "G", "M", "K"} {
+		if n >= sizeUnits[unit] {
+			return fmt.Sprintf("%.1f%s", float64(n)/float64(sizeUnits[unit]), unit)
+		}
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// formatInfo is the part of the JSON of the backend that tells the size of a format.
+type formatInfo struct {
+	Filesize       float64 `json:"filesize"`
+	FilesizeApprox float64 `json:"filesize_approx"`
+	Tbr            float64 `json:"tbr"`
+}
+
+// size returns the size of the format, from its bit rate in kbit/s and duration if the backend does not know it.
+func (f formatInfo) size(duration float64) int64 {
+	switch {
+	case f.Filesize > 0:
+		return int64(f.Filesize)
+	case f.FilesizeApprox > 0:
+		return int64(f.FilesizeApprox)
+	}
+	return int64(f.Tbr * 1000 / 8 * duration)
+}
+
+// Size returns the size of the format the backend chooses for job, 0 if it does not tell.
+func (b Backend) Size(ctx context.Context, job Job) (int64, error) {
+	out, err := b.Command(ctx, job, "-j").Output()
+	if err != nil {
+		return 0, fmt.Errorf("%s -j %s: %w", b.Program, job.Episode.URL, err)
+	}
+	var info struct {
+		formatInfo
+		Duration         float64      `json:"duration"`
+		RequestedFormats []formatInfo `json:"requested_formats"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return 0, fmt.Errorf("format info of %s: %w", job.Episode.URL, err)
+	}
+	if len(info.RequestedFormats) == 0 {
+		return info.size(info.Duration), nil
+	}
+	// Video and audio fetched separately and merged.
+	var size int64
+	for _, f := range info.RequestedFormats {
+		size += f.size(info.Duration)
+	}
+	return size, nil
+}
+
+// Estimate returns the size of all jobs from the average of up to samples of them spread over the list,
+// as asking the backend about every episode of a long show takes too long.
+func (b Backend) Estimate(ctx context.Context, jobs []Job, samples int) (int64, error) {
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+	if samples > len(jobs) {
+		samples = len(jobs)
+	}
+	var total int64
+	known := 0
+	var lastErr error
+	for i := 0; i < samples; i++ {
+		size, err := b.Size(ctx, jobs[i*len(jobs)/samples])
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if size > 0 {
+			total += size
+			known++
+		}
+	}
+	if known == 0 {
+		if lastErr != nil {
+			return 0, lastErr
+		}
+		return 0, errors.New("the backend does not tell the size of the episodes")
+	}
+	return total / int64(known) * int64(len(jobs)), nil
+}
+
+// Preflight compares the space the jobs need with the free space of the download folder.
+type Preflight struct {
+	Needed int64
+	Free   int64
+}
+
+// Short reports whether there is less free space than needed.
+func (p Preflight) Short() bool {
+	return p.Needed > p.Free
+}
+
+func (p Preflight) String() string {
+	return fmt.Sprintf("the episodes need about %s, %s is free", FormatSize(p.Needed), FormatSize(p.Free))
+}
+
+// preflightSamples is the number of episodes whose size is asked for.
+const preflightSamples = 3
+
+// CheckSpace estimates the size of the jobs and the free space of dir, which need not exist yet.
+// A storage cap above 0 bounds what is needed.
+func (b Backend) CheckSpace(ctx context.Context, jobs []Job, dir string, maxBytes int64) (Preflight, error) {
+	p := Preflight{}
+	var err error
+	if p.Needed, err = b.Estimate(ctx, jobs, preflightSamples); err != nil {
+		return p, err
+	}
+	if maxBytes > 0 && p.Needed > maxBytes {
+		p.Needed = maxBytes
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return p, err
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	p.Free, err = freeSpace(dir)
+	return p, err
+}
+
+// filesSize returns the size of the files the backend left for job.
+func filesSize(job Job) int64 {
+	var size int64
+	for _, f := range siblings(job.Base) {
+		if info, err := os.Stat(f); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package download
+
+import "errors"
+
+// freeSpace is not known on this system, the preflight then only reports that.
+func freeSpace(dir string) (int64, error) {
+	return 0, errors.New("free space unknown on this system")
+}
@@ -0,0 +1,99 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    int64
+		wantErr bool
+	}{
+		{text: "", want: 0},
+		{text: "  ", want: 0},
+		{text: "512", want: 512},
+		{text: "2K", want: 2 << 10},
+		{text: "500M", want: 500 << 20},
+		{text: "500mb", want: 500 << 20},
+		{text: "50G", want: 50 << 30},
+		{text: " 1.5T ", want: 3 << 39},
+		{text: "100B", want: 100},
+		{text: "-1G", wantErr: true},
+		{text: "G", wantErr: true},
+		{text: "10X", wantErr: true},
+		{text: "ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseSize(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:             "0B",
+		1023:          "1023B",
+		1 << 10:       "1.0K",
+		1536:          "1.5K",
+		500 << 20:     "500.0M",
+		50 << 30:      "50.0G",
+		3 << 39:       "1.5T",
+		(1 << 30) - 1: "1024.0M",
+	}
+	for n, want := range tests {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestRunStorageCap(t *testing.T) {
+	program, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("no true command to stand in for the backend")
+	}
+	dir, err := ioutil.TempDir("", "space")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// A partial download from before is there, the backend adds nothing to it.
+	if err := ioutil.WriteFile(filepath.Join(dir, "Ep 1.mp4.part"), make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jobs := []Job{
+		{Output: filepath.Join(dir, "Ep 1.%(ext)s"), Base: filepath.Join(dir, "Ep 1")},
+		{Output: filepath.Join(dir, "Ep 2.%(ext)s"), Base: filepath.Join(dir, "Ep 2")},
+	}
+	r := &Runner{Backend: Backend{Program: program}, MaxBytes: 500}
+	results := r.Run(context.Background(), jobs[:1])
+	if results[0].Err != nil || results[0].Skipped != nil {
+		t.Fatalf("got error %v, skipped %v", results[0].Err, results[0].Skipped)
+	}
+	if r.Used != 0 {
+		t.Errorf("used %d bytes, want only the bytes the run added", r.Used)
+	}
+	r.Used = 600
+	results = r.Run(context.Background(), jobs[1:])
+	if !errors.Is(results[0].Skipped, ErrStorageCap) || results[0].Err != nil || results[0].Started {
+		t.Fatalf("got error %v, skipped %v, started %v, want a job skipped at the cap",
+			results[0].Err, results[0].Skipped, results[0].Started)
+	}
+	if len(Failed(results)) != 0 || len(Requeue(results)) != 0 {
+		t.Error("skipped job counted as failed")
+	}
+}
@@ -0,0 +1,190 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/subtitles"
+)
+
+// Subtitles selects the subtitles downloaded with the episodes.
+// Languages are language codes of the backend, Format is one of subtitles.Formats.
+// The backend always fetches TTML, which is converted to Format afterwards.
+// Only skips the video. Mux puts the video and its subtitles into an MKV file, if ffmpeg is installed.
+type Subtitles struct {
+	Enabled   bool     `yaml:"enabled"`
+	Languages []string `yaml:"languages"`
+	Format    string   `yaml:"format"`
+	Only      bool     `yaml:"only"`
+	Mux       bool     `yaml:"mux"`
+}
+
+// Check reports a format that is not one of subtitles.Formats.
+func (s Subtitles) Check() error {
+	for _, f := range subtitles.Formats {
+		if s.Format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown subtitle format %q, use one of %s", s.Format, strings.Join(subtitles.Formats, ", "))
+}
+
+// args returns the backend arguments fetching the subtitles.
+func (s Subtitles) args() []string {
+	if !s.Enabled {
+		return nil
+	}
+	args := []string{"--write-sub", "--sub-format", subtitles.TTML}
+	if len(s.Languages) > 0 {
+		args = append(args, "--sub-lang", strings.Join(s.Languages, ","))
+	}
+	if s.Only {
+		args = append(args, "--skip-download")
+	}
+	return args
+}
+
+// siblings returns the files next to base whose name is the one of base followed by a dot.
+func siblings(base string) []string {
+	entries, err := ioutil.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return nil
+	}
+	prefix := filepath.Base(base) + "."
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) {
+			files = append(files, filepath.Join(filepath.Dir(base), e.Name()))
+		}
+	}
+	return files
+}
+
+// process converts the downloaded TTML files of job to Format and muxes them if asked to.
+func (s Subtitles) process(job Job, out io.Writer) error {
+	if !s.Enabled {
+		return nil
+	}
+	files := []string{}
+	for _, f := range siblings(job.Base) {
+		if !strings.HasSuffix(f, "."+subtitles.TTML) {
+			continue
+		}
+		if s.Format == "" || s.Format == subtitles.TTML {
+			files = append(files, f)
+			continue
+		}
+		converted := strings.TrimSuffix(f, subtitles.TTML) + s.Format
+		if err := convertFile(f, converted, s.Format); err != nil {
+			return err
+		}
+		os.Remove(f)
+		files = append(files, converted)
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(out, "No subtitles found for %s\n", job.Episode.URL)
+		return nil
+	}
+	if s.Mux && !s.Only {
+		return mux(job, files, out)
+	}
+	return nil
+}
+
+// convertFile converts the TTML file from to format in the file to.
+func convertFile(from string, to string, format string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if err := subtitles.Convert(in, out, format); err != nil {
+		out.Close()
+		os.Remove(to)
+		return fmt.Errorf("%s: %w", from, err)
+	}
+	return out.Close()
+}
+
+// mediaExts are the extensions of the video and audio files the backend and ffmpeg write.
+var mediaExts = map[string]bool{".mp4": true, ".mkv": true, ".webm": true, ".m4v": true, ".mov": true, ".flv": true,
+	".ts": true, ".m4a": true, ".mp3": true, ".aac": true, ".ogg": true, ".opus": true, ".flac": true, ".wav": true}
+
+// video returns the downloaded video or audio file of job, empty if there is none.
+// Other files next to it, like .info.json or .description, are not taken for it.
+func video(job Job) string {
+	for _, f := range siblings(job.Base) {
+		if mediaExts[strings.ToLower(filepath.Ext(f))] {
+			return f
+		}
+	}
+	return ""
+}
+
+// mux puts the video of job and the subtitle files into Base.mkv with ffmpeg and removes the separate files.
+// Without ffmpeg the files are left as they are.
+func mux(job Job, files []string, out io.Writer) error {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		fmt.Fprintln(out, "ffmpeg not found, subtitles are kept next to the video")
+		return nil
+	}
+	src := video(job)
+	if src == "" {
+		return fmt.Errorf("no video to mux the subtitles of %s into", job.Episode.URL)
+	}
+	inputs := []string{src}
+	for _, f := range files {
+		if strings.HasSuffix(f, "."+subtitles.TTML) {
+			// ffmpeg does not read TTML.
+			srt := strings.TrimSuffix(f, subtitles.TTML) + "mux.srt"
+			if err := convertFile(f, srt, subtitles.SRT); err != nil {
+				return err
+			}
+			defer os.Remove(srt)
+			f = srt
+		}
+		inputs = append(inputs, f)
+	}
+	dest := job.Base + ".mkv"
+	tmp := job.Base + ".muxing.mkv"
+	args := []string{"-y", "-loglevel", "error"}
+	for _, in := range inputs {
+		args = append(args, "-i", in)
+	}
+	for i := range inputs {
+		args = append(args, "-map", fmt.Sprint(i))
+	}
+	args = append(args, "-c", "copy", "-c:s", "srt")
+	for i, f := range files {
+		// The backend names the files <base>.<language>.<format>.
+		lang := strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(f, filepath.Ext(f))), ".")
+		args = append(args, fmt.Sprintf("-metadata:s:s:%d", i), "language="+lang)
+	}
+	cmd := exec.Command(ffmpeg, append(args, tmp)...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("muxing %s: %w", src, err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return err
+	}
+	if src != dest {
+		os.Remove(src)
+	}
+	for _, f := range files {
+		os.Remove(f)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// touch creates the files in dir.
+func touch(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVideo(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{name: "video", files: []string{"Show - Ep.info.json", "Show - Ep.description", "Show - Ep.mp4"}, want: "Show - Ep.mp4"},
+		{name: "audio", files: []string{"Show - Ep.en.srt", "Show - Ep.M4A"}, want: "Show - Ep.M4A"},
+		{name: "sidecars only", files: []string{"Show - Ep.info.json", "Show - Ep.nfo", "Show - Ep.en.ttml"}},
+		{name: "partial", files: []string{"Show - Ep.mp4.part"}},
+		{name: "other episode", files: []string{"Show - Ep 2.mp4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "video")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			touch(t, dir, tt.files...)
+			want := ""
+			if tt.want != "" {
+				want = filepath.Join(dir, tt.want)
+			}
+			if got := video(Job{Base: filepath.Join(dir, "Show - Ep")}); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRunReportsSubtitleErrors(t *testing.T) {
+	program, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("no true command to stand in for the backend")
+	}
+	dir, err := ioutil.TempDir("", "subtitles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	touch(t, dir, "Show - Ep.mp4")
+	if err := ioutil.WriteFile(filepath.Join(dir, "Show - Ep.en.ttml"), []byte("<tt><p begin="), 0644); err != nil {
+		t.Fatal(err)
+	}
+	job := Job{Output: filepath.Join(dir, "Show - Ep.%(ext)s"), Base: filepath.Join(dir, "Show - Ep"), ShowDir: dir}
+	var out bytes.Buffer
+	r := &Runner{Backend: Backend{Program: program}, Output: &out,
+		Subtitles: Subtitles{Enabled: true, Format: "srt"}}
+	results := r.Run(context.Background(), []Job{job})
+	if results[0].Err != nil {
+		t.Errorf("job failed: %s", results[0].Err)
+	}
+	if !strings.Contains(out.String(), "Failed to process subtitles") {
+		t.Errorf("subtitle error not reported in %q", out.String())
+	}
+}
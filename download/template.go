@@ -0,0 +1,166 @@
+package download
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/epinfo"
+	"github.com/gandalf15/iplayerlinks/ipurl"
+)
+
+// DefaultTemplate names the files after the show and the episode in a folder per show.
+const DefaultTemplate = "{show}/{show} - {title}.{ext}"
+
+// BackendExt is what {ext} becomes when the template is passed to the backend,
+// which then fills in the extension of the file it downloaded.
+const BackendExt = "%(ext)s"
+
+// fieldRe matches a template field like {show} or {sn:02}.
+var fieldRe = regexp.MustCompile(`\{([a-z]+)(?::(0?)(\d+))?\}`)
+
+// number writes a series or episode number, an unknown one is empty.
+func number(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// fields are the values a template can use.
+var fields = map[string]func(ep epinfo.EpisodeInfo) string{
+	"show": func(ep epinfo.EpisodeInfo) string {
+		if ep.TvShow == nil {
+			return ""
+		}
+		return *ep.TvShow
+	},
+	"series": func(ep epinfo.EpisodeInfo) string {
+		if ep.Series == "none" {
+			return ""
+		}
+		return ep.Series
+	},
+	"title":   func(ep epinfo.EpisodeInfo) string { return ep.Label },
+	"sn":      func(ep epinfo.EpisodeInfo) string { return number(ep.SeriesNo) },
+	"en":      func(ep epinfo.EpisodeInfo) string { return number(ep.EpisodeNo) },
+	"variant": func(ep epinfo.EpisodeInfo) string { return ep.Variant() },
+	"date": func(ep epinfo.EpisodeInfo) string {
+		if ep.Aired.IsZero() {
+			return ""
+		}
+		return ep.Aired.Format("2006-01-02")
+	},
+	"year": func(ep epinfo.EpisodeInfo) string {
+		if ep.Aired.IsZero() {
+			return ""
+		}
+		return strconv.Itoa(ep.Aired.Year())
+	},
+	"pid": func(ep epinfo.EpisodeInfo) string {
+		if u, err := ipurl.Parse(ep.URL); err == nil {
+			return u.PID
+		}
+		return ""
+	},
+}
+
+// Template names downloaded files after their EpisodeInfo.
+// Fields are written in braces: {show}, {series}, {title}, {sn} and {en} for the series and episode
+// numbers, {variant}, {date} and {year} of the air date, {pid} and {ext}. Unknown values are empty.
+// Numbers can be padded with zeros like {sn:02}. A "/" in the template starts a folder,
+// the values are sanitised so they never do. Backend fields like %(id)s pass through unchanged.
+type Template struct {
+	text string
+}
+
+// ParseTemplate checks the fields of text.
+func ParseTemplate(text string) (*Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("empty filename template")
+	}
+	for _, m := range fieldRe.FindAllStringSubmatch(text, -1) {
+		if _, ok := fields[m[1]]; !ok && m[1] != "ext" {
+			return nil, fmt.Errorf("unknown field %s in filename template %q", m[0], text)
+		}
+	}
+	if strings.HasPrefix(text, "/") || strings.Contains("/"+text+"/", "/../") {
+		return nil, fmt.Errorf("filename template must stay in the download folder: %q", text)
+	}
+	return &Template{text: text}, nil
+}
+
+// String returns the text of the template.
+func (t *Template) String() string {
+	return t.text
+}
+
+// Resolvable returns an error unless the files of a job can be found from the template alone,
+// as Verify needs: it must not use backend fields and must end in its only .{ext}.
+func (t *Template) Resolvable() error {
+	switch {
+	case strings.Contains(t.text, "%("):
+		return fmt.Errorf("cannot verify the files of filename template %q, it uses backend fields", t.text)
+	case strings.Count(t.text, "{ext}") != 1 || !strings.HasSuffix(t.text, ".{ext}"):
+		return fmt.Errorf("cannot verify the files of filename template %q, it must end in its only .{ext}", t.text)
+	}
+	return nil
+}
+
+// Execute returns the path of the file of ep relative to the download folder, {ext} is replaced by ext.
+func (t *Template) Execute(ep epinfo.EpisodeInfo, ext string) string {
+	return t.execute(ep, ext, func(value string) string { return value })
+}
+
+// Backend returns the output template of ep for the backend,
+// a "%" in the values is doubled so the backend does not read it as one of its fields.
+func (t *Template) Backend(ep epinfo.EpisodeInfo) string {
+	return t.execute(ep, BackendExt, func(value string) string { return strings.ReplaceAll(value, "%", "%%") })
+}
+
+func (t *Template) execute(ep epinfo.EpisodeInfo, ext string, escape func(string) string) string {
+	res := fieldRe.ReplaceAllStringFunc(t.text, func(field string) string {
+		m := fieldRe.FindStringSubmatch(field)
+		if m[1] == "ext" {
+			return ext
+		}
+		value := fields[m[1]](ep)
+		if value == "" {
+			return ""
+		}
+		// Padded after sanitising, which would collapse the spaces.
+		value = escape(Sanitise(value))
+		if width, err := strconv.Atoi(m[3]); err == nil && width > len(value) {
+			pad := " "
+			if m[2] == "0" {
+				pad = "0"
+			}
+			value = strings.Repeat(pad, width-len(value)) + value
+		}
+		return value
+	})
+	parts := []string{}
+	for _, part := range strings.Split(res, "/") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return path.Join(parts...)
+}
+
+// unsafeRe matches characters that are not allowed in file names on some filesystem.
+var unsafeRe = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]`)
+
+// Sanitise makes name safe to use as a file or folder name on Linux, macOS and Windows.
+func Sanitise(name string) string {
+	name = unsafeRe.ReplaceAllString(name, "_")
+	name = strings.Join(strings.Fields(name), " ")
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
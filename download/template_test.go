@@ -0,0 +1,211 @@
+package download
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gandalf15/iplayerlinks/epinfo"
+)
+
+// episode returns an episode of the show with the given numbers.
+func episode(show string, series string, label string, sn int, en int) epinfo.EpisodeInfo {
+	return epinfo.EpisodeInfo{TvShow: &show, Series: series, Label: label, SeriesNo: sn, EpisodeNo: en,
+		URL: "https://www.bbc.co.uk/iplayer/episode/p0000001/example"}
+}
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		text    string
+		wantErr bool
+	}{
+		{text: DefaultTemplate},
+		{text: "{show}/S{sn:02}E{en:02} %(id)s.{ext}"},
+		{text: "", wantErr: true},
+		{text: "  ", wantErr: true},
+		{text: "{show}/{name}.{ext}", wantErr: true},
+		{text: "/{show}.{ext}", wantErr: true},
+		{text: "{show}/../{title}.{ext}", wantErr: true},
+		{text: "../{title}.{ext}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			_, err := ParseTemplate(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateResolvable(t *testing.T) {
+	tests := []struct {
+		text    string
+		wantErr bool
+	}{
+		{text: DefaultTemplate},
+		{text: AlbumTemplate},
+		{text: "{show}/S{sn:02}E{en:02} %(id)s.{ext}", wantErr: true},
+		{text: "{show}/%(title)s.%(ext)s", wantErr: true},
+		{text: "{show}/{title}", wantErr: true},
+		{text: "{show}/{title}.{ext}.mkv", wantErr: true},
+		{text: "{show}/{title}-{ext}", wantErr: true},
+		{text: "{ext}/{title}.{ext}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tmpl.Resolvable(); (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateExecute(t *testing.T) {
+	aired := episode("Show", "Series 2", "Show, Series 2: Episode 3", 2, 3)
+	aired.Aired = time.Date(2021, 3, 19, 0, 0, 0, 0, time.UTC)
+	aired.AudioDescribed = true
+	tests := []struct {
+		name, text  string
+		ep          epinfo.EpisodeInfo
+		want        string
+		wantBackend string
+	}{
+		{
+			name:        "default",
+			text:        DefaultTemplate,
+			ep:          episode("Show", "Series 1", "Episode 1", 1, 1),
+			want:        "Show/Show - Episode 1.mp4",
+			wantBackend: "Show/Show - Episode 1.%(ext)s",
+		},
+		{
+			name:        "padded numbers",
+			text:        "{show}/{series}/S{sn:02}E{en:02} {sn:3}.{ext}",
+			ep:          episode("Show", "Series 2", "Episode 3", 2, 3),
+			want:        "Show/Series 2/S02E03   2.mp4",
+			wantBackend: "Show/Series 2/S02E03   2.%(ext)s",
+		},
+		{
+			name:        "unknown numbers",
+			text:        "{show} - S{sn:02}E{en}.{ext}",
+			ep:          episode("Show", "none", "Christmas Special", 0, 0),
+			want:        "Show - SE.mp4",
+			wantBackend: "Show - SE.%(ext)s",
+		},
+		{
+			name:        "no series folder",
+			text:        "{show}/{series}/{title}.{ext}",
+			ep:          episode("Show", "none", "Special", 0, 0),
+			want:        "Show/Special.mp4",
+			wantBackend: "Show/Special.%(ext)s",
+		},
+		{
+			name:        "date, year, variant and pid",
+			text:        "{year}/{date} {variant} {pid}.{ext}",
+			ep:          aired,
+			want:        "2021/2021-03-19 ad p0000001.mp4",
+			wantBackend: "2021/2021-03-19 ad p0000001.%(ext)s",
+		},
+		{
+			name:        "unsafe values",
+			text:        "{show}/{title}.{ext}",
+			ep:          episode("AC/DC: Live?", "none", "100% <Live>  ", 0, 0),
+			want:        "AC_DC_ Live_/100% _Live_.mp4",
+			wantBackend: "AC_DC_ Live_/100%% _Live_.%(ext)s",
+		},
+		{
+			name:        "backend fields",
+			text:        "{show}/%(id)s.{ext}",
+			ep:          episode("Show", "none", "Special", 0, 0),
+			want:        "Show/%(id)s.mp4",
+			wantBackend: "Show/%(id)s.%(ext)s",
+		},
+		{
+			name:        "no show",
+			text:        "{show}/{title}.{ext}",
+			ep:          epinfo.EpisodeInfo{Label: "Special"},
+			want:        "Special.mp4",
+			wantBackend: "Special.%(ext)s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tmpl.Execute(tt.ep, "mp4"); got != tt.want {
+				t.Errorf("Execute() = %q, want %q", got, tt.want)
+			}
+			if got := tmpl.Backend(tt.ep); got != tt.wantBackend {
+				t.Errorf("Backend() = %q, want %q", got, tt.wantBackend)
+			}
+		})
+	}
+}
+
+func TestJobs(t *testing.T) {
+	dir := filepath.Join("downloads", "tv")
+	ep := episode("Show", "Series 1", "Episode 1", 1, 1)
+	tests := []struct {
+		text                  string
+		output, base, showDir string
+	}{
+		{
+			text:    DefaultTemplate,
+			output:  filepath.Join(dir, "Show", "Show - Episode 1.%(ext)s"),
+			base:    filepath.Join(dir, "Show", "Show - Episode 1"),
+			showDir: filepath.Join(dir, "Show"),
+		},
+		{
+			text:    "{show}/{series}/S{sn:02}E{en:02}.{ext}",
+			output:  filepath.Join(dir, "Show", "Series 1", "S01E01.%(ext)s"),
+			base:    filepath.Join(dir, "Show", "Series 1", "S01E01"),
+			showDir: filepath.Join(dir, "Show"),
+		},
+		{
+			text:    "{show} - {title}.{ext}",
+			output:  filepath.Join(dir, "Show - Episode 1.%(ext)s"),
+			base:    filepath.Join(dir, "Show - Episode 1"),
+			showDir: dir,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jobs := Jobs([]epinfo.EpisodeInfo{ep}, dir, tmpl)
+			if len(jobs) != 1 {
+				t.Fatalf("got %d jobs", len(jobs))
+			}
+			job := jobs[0]
+			if job.Output != tt.output || job.Base != tt.base || job.ShowDir != tt.showDir {
+				t.Errorf("got output %q, base %q, show folder %q, want %q, %q, %q",
+					job.Output, job.Base, job.ShowDir, tt.output, tt.base, tt.showDir)
+			}
+		})
+	}
+}
+
+func TestSanitise(t *testing.T) {
+	tests := map[string]string{
+		"Doctor Who":           "Doctor Who",
+		"Who? What: Where*":    "Who_ What_ Where_",
+		"  lots   of  space  ": "lots of space",
+		"tab\there":            "tab_here",
+		"...":                  "_",
+		".hidden.":             "hidden",
+		"a\\b|c\"d":            "a_b_c_d",
+	}
+	for name, want := range tests {
+		if got := Sanitise(name); got != want {
+			t.Errorf("Sanitise(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
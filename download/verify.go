@@ -0,0 +1,87 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Plausibility limits of a downloaded file
+const (
+	// MinSize is the smallest file taken for an episode.
+	MinSize = 64 << 10
+	// MinDuration is the shortest episode.
+	MinDuration = 10 * time.Second
+	// MinBytesPerSecond is 32 kbit/s, below the bit rate of any iPlayer audio.
+	MinBytesPerSecond = 4000
+)
+
+// ErrVerify is wrapped by the errors of Verify.
+var ErrVerify = errors.New("verification failed")
+
+// Verify checks the files the backend left for job: there must be no partial downloads,
+// and the episode file must exist with a plausible size and, when ffprobe is installed, duration.
+// With subtitles only, a subtitle file must exist instead.
+func Verify(job Job, subs Subtitles) error {
+	for _, f := range siblings(job.Base) {
+		if ext := filepath.Ext(f); ext == ".part" || ext == ".ytdl" {
+			return fmt.Errorf("%w: partial download %s left", ErrVerify, f)
+		}
+	}
+	if subs.Enabled && subs.Only {
+		for _, f := range siblings(job.Base) {
+			if ext := filepath.Ext(f); ext == ".ttml" || ext == ".srt" || ext == ".vtt" {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: no subtitles for %s", ErrVerify, job.Episode.URL)
+	}
+	file := video(job)
+	if file == "" {
+		return fmt.Errorf("%w: no file for %s", ErrVerify, job.Episode.URL)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrVerify, err)
+	}
+	if info.Size() < MinSize {
+		return fmt.Errorf("%w: %s is only %d bytes", ErrVerify, file, info.Size())
+	}
+	d, err := duration(file)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrVerify, err)
+	}
+	if d < 0 {
+		return nil
+	}
+	if d < MinDuration {
+		return fmt.Errorf("%w: %s is only %s long", ErrVerify, file, d)
+	}
+	if rate := float64(info.Size()) / d.Seconds(); rate < MinBytesPerSecond {
+		return fmt.Errorf("%w: %s has %d bytes for %s", ErrVerify, file, info.Size(), d.Round(time.Second))
+	}
+	return nil
+}
+
+// duration returns the duration ffprobe reads from file, -1 without ffprobe.
+func duration(file string) (time.Duration, error) {
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return -1, nil
+	}
+	out, err := exec.Command(ffprobe, "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", file).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe cannot read %s: %w", file, err)
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("no duration for %s", file)
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
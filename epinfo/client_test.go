@@ -0,0 +1,128 @@
+package epinfo
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewClientProxy(t *testing.T) {
+	hosts := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.URL.Host
+	}))
+	defer proxy.Close()
+	client, err := NewClient(ClientOptions{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("http://www.bbc.co.uk/iplayer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if host := <-hosts; host != "www.bbc.co.uk" {
+		t.Errorf("proxy asked for %q, want www.bbc.co.uk", host)
+	}
+	for _, bad := range []string{"proxy:3128", "http://user:secret@", "://"} {
+		_, err := NewClient(ClientOptions{Proxy: bad})
+		if err == nil {
+			t.Errorf("no error for proxy %q", bad)
+		} else if strings.Contains(err.Error(), "secret") {
+			t.Errorf("error %q shows the password", err)
+		}
+	}
+}
+
+func TestNewClientCAFile(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// The client without the CA file fails the handshake, that is not worth a log line.
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(ClientOptions{CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr := client.Transport.(*http.Transport); tr.TLSClientConfig == nil || tr.TLSClientConfig.RootCAs == nil {
+		t.Fatal("no CA pool set")
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("server of the CA file not trusted: %s", err)
+	}
+	resp.Body.Close()
+	plain, err := NewClient(ClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := plain.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("server trusted without the CA file")
+	}
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := ioutil.WriteFile(garbage, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{garbage, filepath.Join(dir, "missing.pem")} {
+		if _, err := NewClient(ClientOptions{CAFile: bad}); err == nil {
+			t.Errorf("no error for CA file %s", bad)
+		}
+	}
+}
+
+func TestNewClientUserAgent(t *testing.T) {
+	agents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents <- r.UserAgent()
+	}))
+	defer server.Close()
+	client, err := NewClient(ClientOptions{UserAgent: "iplayerlinks-test/1.0", Cookies: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Jar == nil {
+		t.Error("no cookie jar")
+	}
+	tests := []struct {
+		name, header, want string
+	}{
+		{name: "set", want: "iplayerlinks-test/1.0"},
+		{name: "kept", header: "own/2.0", want: "own/2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set("User-Agent", tt.header)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := <-agents; got != tt.want {
+				t.Errorf("User-Agent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
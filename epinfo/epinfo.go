@@ -1,168 +1,218 @@
 package epinfo
 
 import (
-
-	//for sending HTTP requests
-
+	"context"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/gandalf15/iplayerlinks/ipurl"
 	"golang.org/x/net/html" //for URL formatting
 )
 
 // EpisodeInfo struct holds info about an episode
+// SeriesNo, EpisodeNo and Aired are read from the labels and are zero when not known.
 type EpisodeInfo struct {
 	TvShow                   *string
 	Label, Series, URL       string
 	AudioDescribed, SignLang bool
+	SeriesNo, EpisodeNo      int
+	Aired                    time.Time
+}
+
+// newEpisode returns the EpisodeInfo of a link with the numbers and date found in its labels.
+func newEpisode(tvShow *string, label string, series string, href string, audioDescribed bool, signLang bool) EpisodeInfo {
+	ep := EpisodeInfo{TvShow: tvShow, Label: label, Series: series, URL: href,
+		AudioDescribed: audioDescribed, SignLang: signLang}
+	ep.SeriesNo, ep.EpisodeNo = labelNumbers(series, label)
+	ep.Aired = labelDate(label)
+	return ep
+}
+
+// Variant returns "ad" for audio described, "sign" for sign language and "standard" for other episodes.
+func (ep EpisodeInfo) Variant() string {
+	switch {
+	case ep.AudioDescribed:
+		return "ad"
+	case ep.SignLang:
+		return "sign"
+	}
+	return "standard"
+}
+
+var (
+	// fetchMu guards fetchSlots.
+	fetchMu sync.Mutex
+	// fetchSlots limits the number of pages fetched at the same time, nil means no limit.
+	fetchSlots chan struct{}
+)
+
+// SetMaxConcurrentFetches caps the number of pages fetched at the same time by all scrapes.
+// n <= 0 removes the cap. Setting the same cap again changes nothing, another cap lets
+// the fetches running when it is called finish under the old one.
+func SetMaxConcurrentFetches(n int) {
+	fetchMu.Lock()
+	defer fetchMu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	if n == cap(fetchSlots) {
+		// Keep the slots the running fetches hold.
+		return
+	}
+	if n == 0 {
+		fetchSlots = nil
+	} else {
+		fetchSlots = make(chan struct{}, n)
+	}
+}
+
+// StatusError is returned for a page iPlayer answered with an HTTP error instead of the page,
+// such pages are not read by the extractors.
+type StatusError struct {
+	URL  string
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s: HTTP %d %s", e.URL, e.Code, http.StatusText(e.Code))
+	if e.Code == http.StatusForbidden {
+		msg += ", iPlayer may not be available from where you are"
+	}
+	return msg
 }
 
-func bodyNode(url string) *html.Node {
-	resp, err := http.Get(url)
+// fetchPage returns the raw bytes and the parsed HTML of the page at url.
+// The request is abandoned when ctx is cancelled, a response that is not 2xx is a *StatusError.
+func fetchPage(ctx context.Context, url string) ([]byte, *html.Node, error) {
+	fetchMu.Lock()
+	slots := fetchSlots
+	fetchMu.Unlock()
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		defer func() { <-slots }()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
+	}
+	resp, err := currentClient().Do(req)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, &StatusError{URL: url, Code: resp.StatusCode}
+	}
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
 	body, err := html.Parse(strings.NewReader(string(bodyBytes)))
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
-	return body
+	return bodyBytes, body, nil
+}
+
+// background returns a session that is never cancelled and reports no progress.
+func background() *session {
+	return newSession(context.Background(), nil)
 }
 
 // SeriesEpisodes return all episodes found on a given url.
 // You can select if you want to include audio described and sign language links.
+// The registered extractors are tried in order, see RegisterExtractor.
 func SeriesEpisodes(pageURL string, audioDescribed bool, signLang bool, ch chan []EpisodeInfo) {
-	pageVisited := make(map[string]bool)
-	pageVisited[pageURL] = true
-	body := bodyNode(pageURL)
-	episodes := []EpisodeInfo{}
-	tvShow := ""
-	tvShowFound := false
-	var f func(*html.Node)
-	// Depth-first order processing
-	f = func(node *html.Node) {
-		if node.Type == html.ElementNode {
-			if node.Data == "a" {
-				href := ""
-				label := ""
-				series := "none"
-				for _, attr := range node.Attr {
-					switch attr.Key {
-					case "href":
-						if strings.Contains(attr.Val, "/iplayer/episode/") {
-							href = "https://www.bbc.co.uk" + attr.Val
-						} else if strings.Contains(attr.Val, "?page=") {
-							i := strings.LastIndex(attr.Val, "?page=")
-							_, ok := pageVisited[pageURL+attr.Val[i:]]
-							if !ok {
-								pageVisited[pageURL+attr.Val[i:]] = false
-							}
-						}
-					case "aria-label":
-						label = attr.Val
-					case "data-bbc-container":
-						series = attr.Val
-					}
-				}
-				if href != "" && label != "" && series != "contextual-cta" {
-					if strings.Contains(href, "/ad/") {
-						if audioDescribed {
-							newEpisode := EpisodeInfo{&tvShow, label, series, href, true, false}
-							episodes = append(episodes, newEpisode)
-						}
-					} else if strings.Contains(href, "/sign/") {
-						if signLang {
-							newEpisode := EpisodeInfo{&tvShow, label, series, href, false, true}
-							episodes = append(episodes, newEpisode)
-						}
-					} else {
-						newEpisode := EpisodeInfo{&tvShow, label, series, href, false, false}
-						episodes = append(episodes, newEpisode)
-					}
+	s := background()
+	res := seriesEpisodes(s, pageURL, audioDescribed, signLang)
+	if err := s.Err(); err != nil {
+		log.Fatal(err)
+	}
+	ch <- res.episodes
+}
 
-				}
-			} else if node.Data == "h1" {
-				for _, attr := range node.Attr {
-					if !tvShowFound {
-						if attr.Key == "class" && strings.Contains(attr.Val, "title") {
-							tvShow = node.FirstChild.Data
-						}
-					}
-				}
-			}
+// htmlSeriesEpisodes walks the episode links of the page and of its other pages.
+// It reports false when no episode link was found at all.
+func htmlSeriesEpisodes(fetcher Fetcher, pageURL string, body *html.Node, audioDescribed bool, signLang bool) ([]EpisodeInfo, bool) {
+	parser := NewPageParser(audioDescribed, signLang)
+	pageVisited := map[string]bool{pageURL: true}
+	if !strings.Contains(pageURL, "page=") {
+		pageVisited[withQuery(pageURL, "page", "1")] = true
+	}
+	page := parser.Parse(pageURL, body)
+	fetcher.Found(pageURL, page.Episodes)
+	episodes := append([]EpisodeInfo{}, page.Episodes...)
+	found := page.Recognised
+	queue := page.PageLinks
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if pageVisited[next] {
+			continue
 		}
-		for c := node.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
+		pageVisited[next] = true
+		nextBody, err := fetcher.Fetch(next)
+		if err != nil {
+			break
 		}
+		page = parser.Parse(next, nextBody)
+		fetcher.Found(next, page.Episodes)
+		episodes = append(episodes, page.Episodes...)
+		found = found || page.Recognised
+		queue = append(queue, page.PageLinks...)
 	}
-	f(body)
+	return episodes, found
+}
 
-	for url, visited := range pageVisited {
-		if !visited {
-			body := bodyNode(url)
-			var f func(*html.Node)
-			// Depth-first order processing
-			f = func(node *html.Node) {
-				if node.Type == html.ElementNode && node.Data == "a" {
-					href := ""
-					label := ""
-					series := "none"
-					for _, attr := range node.Attr {
-						switch attr.Key {
-						case "href":
-							if strings.Contains(attr.Val, "/iplayer/episode/") {
-								href = "https://www.bbc.co.uk" + attr.Val
-							} else if strings.Contains(attr.Val, "?page=") {
-								i := strings.LastIndex(attr.Val, "?page=")
-								_, ok := pageVisited[url+attr.Val[i:]]
-								if !ok {
-									pageVisited[url+attr.Val[i:]] = false
-								}
-							}
-						case "aria-label":
-							label = attr.Val
-						case "data-bbc-container":
-							series = attr.Val
-						}
-					}
-					if href != "" && label != "" && series != "contextual-cta" {
-						if strings.Contains(href, "/ad/") {
-							if audioDescribed {
-								newEpisode := EpisodeInfo{&tvShow, label, series, href, true, false}
-								episodes = append(episodes, newEpisode)
-							}
-						} else if strings.Contains(href, "/sign/") {
-							if signLang {
-								newEpisode := EpisodeInfo{&tvShow, label, series, href, false, true}
-								episodes = append(episodes, newEpisode)
-							}
-						} else {
-							newEpisode := EpisodeInfo{&tvShow, label, series, href, false, false}
-							episodes = append(episodes, newEpisode)
-						}
-					}
-				}
-				for c := node.FirstChild; c != nil; c = c.NextSibling {
-					f(c)
-				}
-			}
-			f(body)
+// SeriesURLs returns all links to series web pages
+func SeriesURLs(pageURL string) map[string]string {
+	_, body, err := fetchPage(context.Background(), pageURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	series, _, err := seriesNav(pageURL, body)
+	if err != nil {
+		log.Println(err)
+	}
+	return series
+}
+
+// seriesNav runs the series extractors like seriesURLs. When none matches, the error tells
+// a series navigation whose links conflict apart from a page without one.
+func seriesNav(pageURL string, body *html.Node) (map[string]string, string, error) {
+	series, extractor := seriesURLs(pageURL, body)
+	if extractor == "" {
+		if _, err := htmlSeriesNav(pageURL, body); err != nil {
+			return series, extractor, err
 		}
 	}
-	ch <- episodes
+	return series, extractor, nil
 }
 
-// SeriesURLs returns all links to series web pages
-func SeriesURLs(pageURL string) map[string]string {
-	body := bodyNode(pageURL)
+// htmlSeriesURLs reads the series navigation buttons of the page, see htmlSeriesNav.
+// A navigation with conflicting links is not recognised.
+func htmlSeriesURLs(pageURL string, body *html.Node) (map[string]string, bool) {
+	series, err := htmlSeriesNav(pageURL, body)
+	if err != nil {
+		return nil, false
+	}
+	return series, len(series) > 0
+}
+
+// htmlSeriesNav reads the series navigation buttons of the page.
+// It fails when a series is linked to two different pages.
+func htmlSeriesNav(pageURL string, body *html.Node) (map[string]string, error) {
 	series := make(map[string]string)
+	var err error
 	var f func(*html.Node)
 	// Depth-first order processing
 	f = func(node *html.Node) {
@@ -171,23 +221,25 @@ func SeriesURLs(pageURL string) map[string]string {
 			seriesName := ""
 			for _, attr := range node.Attr {
 				if attr.Key == "class" && strings.Contains(attr.Val, "series-nav__button") {
-					seriesName = (node.FirstChild).FirstChild.Data
+					seriesName = nodeText(node)
 				} else if attr.Key == "href" && strings.Contains(attr.Val, "?seriesId=") {
-					href = "https://www.bbc.co.uk" + attr.Val
+					href = ipurl.Resolve(pageURL, attr.Val)
 				}
 			}
 			if href != "" && seriesName != "" {
 				existingURL, ok := series[seriesName]
 				if !ok {
 					series[seriesName] = href
-				} else if existingURL != href {
-					log.Fatalf("Series Name: %s has already link: %s but also found: %s", seriesName, existingURL, href)
+				} else if existingURL != href && err == nil {
+					err = fmt.Errorf("series %s of %s is linked to both %s and %s", seriesName, pageURL, existingURL, href)
 				}
 			}
 		} else if node.Type == html.ElementNode && node.Data == "span" {
 			for _, attr := range node.Attr {
 				if attr.Key == "class" && strings.Contains(attr.Val, "series-nav__button") {
-					series[(node.FirstChild).FirstChild.Data] = pageURL
+					if seriesName := nodeText(node); seriesName != "" {
+						series[seriesName] = pageURL
+					}
 				}
 				break
 			}
@@ -197,7 +249,7 @@ func SeriesURLs(pageURL string) map[string]string {
 		}
 	}
 	f(body)
-	return series
+	return series, err
 }
 
 // AllEpisodesInfo returns a map of all series, if exist, and their episodes of a given BBC iPlayer URL.
@@ -205,28 +257,86 @@ func SeriesURLs(pageURL string) map[string]string {
 // It depends on the BBC iPlayer web page how the episodes are presented.
 // signLang set true if you want to include sign language links.
 // audioDescribed set true if you want to include audio descriabed links.
+// Pages that no extractor recognises are logged and skipped, use AllEpisodesInfoWithDiagnostics to get the error.
+// For an episode URL the episodes list it belongs to is used, see SingleEpisodeInfo for the episode alone.
 func AllEpisodesInfo(pageURL string, audioDescribed bool, signLang bool) map[string][]EpisodeInfo {
-	urlSuffixes := []string{"?page=", "?seriesId="}
-	for _, s := range urlSuffixes {
-		suffixIndex := strings.LastIndex(pageURL, s)
-		if suffixIndex != -1 {
-			pageURL = pageURL[:suffixIndex]
-		}
+	allSeriesEpisodes, _, err := AllEpisodesInfoWithDiagnostics(pageURL, audioDescribed, signLang)
+	if err != nil {
+		log.Println(err)
+	}
+	return allSeriesEpisodes
+}
+
+// AllEpisodesInfoWithDiagnostics works like AllEpisodesInfo but also reports which extractor matched each page.
+// If a series page is not recognised by any extractor, a *LayoutError is returned
+// together with the episodes of the series that were recognised.
+func AllEpisodesInfoWithDiagnostics(pageURL string, audioDescribed bool, signLang bool) (map[string][]EpisodeInfo, Diagnostics, error) {
+	return AllEpisodesInfoContext(context.Background(), pageURL, audioDescribed, signLang, nil)
+}
+
+// AllEpisodesInfoContext works like AllEpisodesInfoWithDiagnostics but stops fetching pages once ctx is cancelled
+// and calls onProgress, if not nil, whenever a page is fetched or series and episodes are found.
+// onProgress is called from the goroutines of the scrape.
+// The episodes found before a failed fetch or the cancellation are returned with the error.
+func AllEpisodesInfoContext(ctx context.Context, pageURL string, audioDescribed bool, signLang bool,
+	onProgress func(Progress)) (map[string][]EpisodeInfo, Diagnostics, error) {
+	return allEpisodes(newSession(ctx, onProgress), pageURL, audioDescribed, signLang)
+}
+
+// allEpisodes scrapes every series of a show within session s.
+func allEpisodes(s *session, pageURL string, audioDescribed bool, signLang bool) (map[string][]EpisodeInfo, Diagnostics, error) {
+	diag := Diagnostics{Episodes: make(map[string]string), SeriesURLs: make(map[string]string)}
+	allSeriesEpisodes := make(map[string][]EpisodeInfo)
+	pageURL, err := episodesListURL(s, pageURL)
+	if err != nil {
+		return allSeriesEpisodes, diag, err
 	}
-	foundSeriesURLs := SeriesURLs(pageURL)
+	diag.ListURL = pageURL
+	raw, body, err := s.fetch(pageURL)
+	if err != nil {
+		return allSeriesEpisodes, diag, err
+	}
+	// A navigation that cannot be read leaves the series of the page, its error is kept.
+	foundSeriesURLs, extractor, err := seriesNav(pageURL, body)
+	diag.Series = extractor
 	if len(foundSeriesURLs) == 0 {
 		foundSeriesURLs["none"] = pageURL
 	}
-	ch := make(chan []EpisodeInfo, len(foundSeriesURLs))
+	for name, sURL := range foundSeriesURLs {
+		s.emit(Event{Kind: SeriesFound, URL: sURL, Series: name})
+	}
+	ch := make(chan seriesResult, len(foundSeriesURLs))
 	for _, sURL := range foundSeriesURLs {
-		go SeriesEpisodes(sURL, audioDescribed, signLang, ch)
+		go func(sURL string) {
+			if sURL == pageURL {
+				ch <- extractEpisodes(s, pageURL, raw, body, audioDescribed, signLang)
+			} else {
+				ch <- seriesEpisodes(s, sURL, audioDescribed, signLang)
+			}
+		}(sURL)
 	}
-	allSeriesEpisodes := make(map[string][]EpisodeInfo)
 	for range foundSeriesURLs {
-		epArr := <-ch
-		if len(epArr) > 0 {
-			allSeriesEpisodes[epArr[0].Series] = epArr
+		res := <-ch
+		diag.Episodes[res.url] = res.extractor
+		if res.err != nil && err == nil {
+			err = res.err
+		}
+		if len(res.episodes) > 0 {
+			allSeriesEpisodes[res.episodes[0].Series] = res.episodes
+			diag.SeriesURLs[res.episodes[0].Series] = res.url
 		}
 	}
-	return allSeriesEpisodes
+	scraped := make(map[string]bool)
+	for _, sURL := range diag.SeriesURLs {
+		scraped[sURL] = true
+	}
+	for name, sURL := range foundSeriesURLs {
+		if !scraped[sURL] && name != "none" {
+			diag.SeriesURLs[name] = sURL
+		}
+	}
+	if fetchErr := s.Err(); fetchErr != nil {
+		err = fetchErr
+	}
+	return allSeriesEpisodes, diag, err
 }
@@ -0,0 +1,127 @@
+package epinfo
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// pageServer answers requests to iPlayer with the pages saved in testdata, by path and query.
+// The pages in broken fail like a lost connection, the ones in hanging are answered only when
+// the request is cancelled, others not found are a 404.
+type pageServer struct {
+	t       *testing.T
+	pages   map[string]string
+	broken  map[string]bool
+	hanging map[string]bool
+	server  *httptest.Server
+}
+
+// servePages sends the requests of all scrapes to a pageServer until the test ends.
+func servePages(t *testing.T, pages map[string]string, broken ...string) *pageServer {
+	t.Helper()
+	p := &pageServer{t: t, pages: pages, broken: map[string]bool{}, hanging: map[string]bool{}}
+	for _, b := range broken {
+		p.broken[b] = true
+	}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.hanging[r.URL.RequestURI()] {
+			<-r.Context().Done()
+			return
+		}
+		name, ok := p.pages[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(data)
+	}))
+	SetHTTPClient(&http.Client{Transport: p})
+	t.Cleanup(func() {
+		SetHTTPClient(nil)
+		p.server.Close()
+	})
+	return p
+}
+
+// idle closes the connections left open, so they are not counted as leaked goroutines.
+func (p *pageServer) idle() {
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	p.server.CloseClientConnections()
+}
+
+// RoundTrip sends req to the test server instead of iPlayer.
+func (p *pageServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if p.broken[req.URL.RequestURI()] {
+		return nil, errors.New("boom")
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = p.server.Listener.Addr().String()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestSetMaxConcurrentFetches(t *testing.T) {
+	defer SetMaxConcurrentFetches(0)
+	SetMaxConcurrentFetches(2)
+	slots := fetchSlots
+	SetMaxConcurrentFetches(2)
+	if fetchSlots != slots {
+		t.Error("setting the same cap replaced the slots")
+	}
+	SetMaxConcurrentFetches(-1)
+	if fetchSlots != nil {
+		t.Errorf("cap %d after removing it", cap(fetchSlots))
+	}
+	// The GUI sets the cap on every scrape, go test -race catches unguarded writes.
+	var wg sync.WaitGroup
+	for i := 1; i <= 4; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			SetMaxConcurrentFetches(n)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestFetchPageStatus(t *testing.T) {
+	servePages(t, map[string]string{"/iplayer/episodes/b0000001/example-show": "show_example.html"})
+	dir, err := ioutil.TempDir("", "saved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	SetSavedPagesDir(dir)
+	defer SetSavedPagesDir("")
+	_, _, err = AllEpisodesInfoContext(context.Background(), "https://www.bbc.co.uk/iplayer/episodes/b0000002/missing",
+		false, false, nil)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatalf("error = %v, want a 404 status error", err)
+	}
+	var layoutErr *LayoutError
+	if errors.As(err, &layoutErr) {
+		t.Errorf("error = %v, want no layout error", err)
+	}
+	if saved, _ := ioutil.ReadDir(dir); len(saved) > 0 {
+		t.Errorf("saved %d pages of an HTTP error", len(saved))
+	}
+	series, _, err := AllEpisodesInfoContext(context.Background(), showURL, false, false, nil)
+	if err != nil || len(series["Series 1"]) != 2 {
+		t.Errorf("got %v and error %v, want the 2 episodes of the saved page", series, err)
+	}
+}
@@ -0,0 +1,192 @@
+package epinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/ipurl"
+	"golang.org/x/net/html"
+)
+
+// ErrNoParent is returned when an episode page does not link to its episodes list.
+var ErrNoParent = errors.New("episode page does not link to its episodes list")
+
+// episodePage is what an episode page tells about the episode and its list.
+type episodePage struct {
+	episodes []EpisodeInfo
+	listURL  string
+}
+
+// parseEpisodePage reads an episode page, preferring the page state over the HTML.
+func parseEpisodePage(pageURL string, body *html.Node, audioDescribed bool, signLang bool) (episodePage, error) {
+	u, err := ipurl.Parse(pageURL)
+	if err != nil {
+		return episodePage{}, err
+	}
+	state, ok := findState(body)
+	if ok && state.Episode.ID != "" {
+		return stateEpisodePage(pageURL, state, audioDescribed, signLang), nil
+	}
+	tleoID := ""
+	if ok {
+		tleoID = state.Episode.TleoID
+	}
+	return htmlEpisodePage(pageURL, u, body, tleoID, audioDescribed, signLang), nil
+}
+
+// stateEpisodePage reads the episode and its brand from the page state.
+func stateEpisodePage(pageURL string, state *pageState, audioDescribed bool, signLang bool) episodePage {
+	page := episodePage{}
+	if state.Episode.TleoID != "" {
+		page.listURL = (&ipurl.URL{Kind: ipurl.Episodes, PID: state.Episode.TleoID}).String()
+	}
+	tvShow := state.Header.Title
+	page.episodes = state.Episode.variants(pageURL, &tvShow, state.currentSeries(), audioDescribed, signLang)
+	return page
+}
+
+// isHeader reports whether node is the header or breadcrumb of a page, where an episode page links to its brand.
+func isHeader(node *html.Node) bool {
+	if node.Data == "header" {
+		return true
+	}
+	for _, attr := range node.Attr {
+		if attr.Key == "class" && (strings.Contains(attr.Val, "breadcrumb") || strings.Contains(attr.Val, "header")) {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlEpisodePage reads the title and the links of an episode page.
+// The episodes list is the one the header links to, or the one of tleoID when the page state names it,
+// links to other shows elsewhere on the page are not taken for it.
+func htmlEpisodePage(pageURL string, self *ipurl.URL, body *html.Node, tleoID string, audioDescribed bool, signLang bool) episodePage {
+	page := episodePage{}
+	tvShow := ""
+	label := ""
+	variants := map[string]string{}
+	if self.Variant != "" {
+		variants[self.Variant] = self.String()
+	}
+	var f func(*html.Node, bool)
+	f = func(node *html.Node, header bool) {
+		if node.Type == html.ElementNode {
+			header = header || isHeader(node)
+			attrs := map[string]string{}
+			for _, attr := range node.Attr {
+				attrs[attr.Key] = attr.Val
+			}
+			switch node.Data {
+			case "meta":
+				if attrs["property"] == "og:title" && label == "" {
+					label = attrs["content"]
+				}
+			case "h1":
+				if tvShow == "" && node.FirstChild != nil && node.FirstChild.Type == html.TextNode {
+					tvShow = strings.TrimSpace(node.FirstChild.Data)
+				}
+			case "a":
+				u, err := ipurl.Parse(ipurl.Resolve(pageURL, attrs["href"]))
+				if err != nil {
+					break
+				}
+				parent := (header && tleoID == "") || u.PID == tleoID
+				if u.Kind == ipurl.Episodes && page.listURL == "" && parent {
+					page.listURL = u.ListURL()
+				} else if u.Kind == ipurl.Episode && u.PID == self.PID && u.Variant != "" {
+					variants[u.Variant] = u.String()
+				}
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c, header)
+		}
+	}
+	f(body, false)
+	if label == "" {
+		label = tvShow
+	}
+	self.Variant = ""
+	page.episodes = append(page.episodes, newEpisode(&tvShow, label, "none", self.String(), false, false))
+	if href, ok := variants["ad"]; ok && audioDescribed {
+		page.episodes = append(page.episodes, newEpisode(&tvShow, label, "none", href, true, false))
+	}
+	if href, ok := variants["sign"]; ok && signLang {
+		page.episodes = append(page.episodes, newEpisode(&tvShow, label, "none", href, false, true))
+	}
+	return page
+}
+
+// SingleEpisodeInfo returns the metadata of the episode at an /iplayer/episode/ URL
+// followed by its audio described and sign language versions if asked for and available.
+func SingleEpisodeInfo(pageURL string, audioDescribed bool, signLang bool) ([]EpisodeInfo, error) {
+	return singleEpisode(background(), pageURL, audioDescribed, signLang)
+}
+
+// SingleEpisodeInfoContext works like SingleEpisodeInfo but abandons the page once ctx is cancelled
+// and calls onProgress, if not nil, when the page is fetched and its episodes are found.
+func SingleEpisodeInfoContext(ctx context.Context, pageURL string, audioDescribed bool, signLang bool,
+	onProgress func(Progress)) ([]EpisodeInfo, error) {
+	return singleEpisode(newSession(ctx, onProgress), pageURL, audioDescribed, signLang)
+}
+
+func singleEpisode(s *session, pageURL string, audioDescribed bool, signLang bool) ([]EpisodeInfo, error) {
+	u, err := ipurl.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Kind != ipurl.Episode {
+		return nil, fmt.Errorf("not an episode URL: %s", pageURL)
+	}
+	body, err := s.Fetch(u.String())
+	if err != nil {
+		return nil, err
+	}
+	page, err := parseEpisodePage(u.String(), body, audioDescribed, signLang)
+	if err == nil {
+		s.Found(u.String(), page.episodes)
+	}
+	return page.episodes, err
+}
+
+// EpisodesListURL returns the episodes list an /iplayer/episode/ URL belongs to.
+// Other iPlayer URLs are returned as their canonical list without fetching anything.
+func EpisodesListURL(pageURL string) (string, error) {
+	return episodesListURL(background(), pageURL)
+}
+
+// EpisodesListURLContext works like EpisodesListURL but abandons the episode page once ctx is cancelled.
+func EpisodesListURLContext(ctx context.Context, pageURL string) (string, error) {
+	return episodesListURL(newSession(ctx, nil), pageURL)
+}
+
+func episodesListURL(s *session, pageURL string) (string, error) {
+	u, err := ipurl.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Kind == ipurl.Listing {
+		return "", fmt.Errorf("listing of many shows, see CrawlListing: %s", pageURL)
+	}
+	if u.Kind == ipurl.Search {
+		return "", fmt.Errorf("search results, see Search: %s", pageURL)
+	}
+	if u.Kind != ipurl.Episode {
+		return u.ListURL(), nil
+	}
+	body, err := s.Fetch(u.String())
+	if err != nil {
+		return "", err
+	}
+	page, err := parseEpisodePage(u.String(), body, false, false)
+	if err != nil {
+		return "", err
+	}
+	if page.listURL == "" {
+		return "", fmt.Errorf("%w: %s", ErrNoParent, pageURL)
+	}
+	return page.listURL, nil
+}
@@ -0,0 +1,59 @@
+package epinfo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseEpisodePage(t *testing.T) {
+	const episodeURL = "https://www.bbc.co.uk/iplayer/episode/p0000002/example-show-series-1-episode-2"
+	tests := []struct {
+		page     string
+		listURL  string
+		episodes []string
+	}{
+		{
+			page:    "episode_links.html",
+			listURL: "https://www.bbc.co.uk/iplayer/episodes/b0000001/example-show",
+			episodes: []string{
+				episodeURL,
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/ad/example-show-series-1-episode-2",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/sign/example-show-series-1-episode-2",
+			},
+		},
+		{
+			page:     "episode_tleo.html",
+			listURL:  "https://www.bbc.co.uk/iplayer/episodes/b0000001/example-show",
+			episodes: []string{episodeURL},
+		},
+		{
+			page:     "episode_orphan.html",
+			episodes: []string{episodeURL},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			page, err := parseEpisodePage(episodeURL, fixture(t, tt.page), true, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.listURL != tt.listURL {
+				t.Errorf("list = %q, want %q", page.listURL, tt.listURL)
+			}
+			if got := urls(page.episodes); !reflect.DeepEqual(got, tt.episodes) {
+				t.Errorf("episodes = %q, want %q", got, tt.episodes)
+			}
+		})
+	}
+}
+
+func TestSingleEpisodeInfoCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := SingleEpisodeInfoContext(ctx, "https://www.bbc.co.uk/iplayer/episode/p0000002", false, false, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+}
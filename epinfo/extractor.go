@@ -0,0 +1,148 @@
+package epinfo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Extractor is a named strategy for reading series and episodes from a page.
+// Each function reports false when it does not recognise the page layout.
+// Episodes follows the other pages of the list with fetcher, Page reads all variants of a single page only.
+// When fetcher fails Episodes returns what it has found so far.
+// Episodes should report each page to fetcher.Found, otherwise all its episodes are reported at the end.
+type Extractor struct {
+	Name       string
+	SeriesURLs func(pageURL string, body *html.Node) (map[string]string, bool)
+	Episodes   func(fetcher Fetcher, pageURL string, body *html.Node, audioDescribed bool, signLang bool) ([]EpisodeInfo, bool)
+	Page       func(pageURL string, body *html.Node) ([]EpisodeInfo, bool)
+}
+
+// extractors are tried in order until one recognises the page.
+var extractors = []Extractor{
+	{Name: "page-state", SeriesURLs: stateSeriesURLs, Episodes: stateSeriesEpisodes, Page: statePage},
+	{Name: "html-links", SeriesURLs: htmlSeriesURLs, Episodes: htmlSeriesEpisodes, Page: htmlPage},
+}
+
+// RegisterExtractor adds an extraction strategy.
+// It is tried after the built-in ones. Functions left nil are skipped.
+func RegisterExtractor(e Extractor) {
+	extractors = append(extractors, e)
+}
+
+// Extractors returns the names of all strategies in the order they are tried.
+func Extractors() []string {
+	names := []string{}
+	for _, e := range extractors {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// Diagnostics reports which extractor matched the pages of a scrape.
+// An empty name means no extractor recognised the page.
+// ListURL is the episodes list that was scraped, it differs from the given URL for episode pages.
+// SeriesURLs maps each series to the page its episodes were read from,
+// series of the navigation without any episode are included too.
+type Diagnostics struct {
+	ListURL    string
+	Series     string
+	Episodes   map[string]string
+	SeriesURLs map[string]string
+}
+
+// LayoutError is returned when no extractor recognises a page.
+// SavedHTML is the path of a copy of the page, empty if saving it failed.
+type LayoutError struct {
+	URL       string
+	Tried     []string
+	SavedHTML string
+}
+
+func (e *LayoutError) Error() string {
+	msg := fmt.Sprintf("page layout not recognised: %s (tried %s)", e.URL, strings.Join(e.Tried, ", "))
+	if e.SavedHTML != "" {
+		msg += ", page saved to " + e.SavedHTML
+	}
+	return msg
+}
+
+// savedPagesDir is where unrecognised pages are saved, empty for the temporary directory.
+var savedPagesDir string
+
+// SetSavedPagesDir sets the directory unrecognised pages are saved to, it is created when needed.
+// An empty dir saves them to the temporary directory.
+func SetSavedPagesDir(dir string) {
+	savedPagesDir = dir
+}
+
+// saveHTML keeps a copy of an unrecognised page.
+func saveHTML(raw []byte) string {
+	if savedPagesDir != "" {
+		if err := os.MkdirAll(savedPagesDir, 0755); err != nil {
+			log.Printf("Failed to save unrecognised page: %s", err)
+			return ""
+		}
+	}
+	f, err := ioutil.TempFile(savedPagesDir, "iplayerlinks-*.html")
+	if err != nil {
+		log.Printf("Failed to save unrecognised page: %s", err)
+		return ""
+	}
+	defer f.Close()
+	if _, err := f.Write(raw); err != nil {
+		log.Printf("Failed to save unrecognised page: %s", err)
+		return ""
+	}
+	return f.Name()
+}
+
+// seriesResult is what one series page yields.
+type seriesResult struct {
+	url, extractor string
+	episodes       []EpisodeInfo
+	err            error
+}
+
+func seriesEpisodes(s *session, pageURL string, audioDescribed bool, signLang bool) seriesResult {
+	raw, body, err := s.fetch(pageURL)
+	if err != nil {
+		return seriesResult{url: pageURL, err: err}
+	}
+	return extractEpisodes(s, pageURL, raw, body, audioDescribed, signLang)
+}
+
+// extractEpisodes runs the episode extractors over an already fetched page.
+func extractEpisodes(s *session, pageURL string, raw []byte, body *html.Node, audioDescribed bool, signLang bool) seriesResult {
+	for _, e := range extractors {
+		if e.Episodes == nil {
+			continue
+		}
+		reporter := &pageReporter{session: s}
+		if episodes, ok := e.Episodes(reporter, pageURL, body, audioDescribed, signLang); ok {
+			if !reporter.reported {
+				s.Found(pageURL, episodes)
+			}
+			return seriesResult{url: pageURL, extractor: e.Name, episodes: episodes}
+		}
+	}
+	err := &LayoutError{URL: pageURL, Tried: Extractors(), SavedHTML: saveHTML(raw)}
+	return seriesResult{url: pageURL, err: err}
+}
+
+// seriesURLs runs the series extractors and returns the links with the name of the one that matched.
+func seriesURLs(pageURL string, body *html.Node) (map[string]string, string) {
+	for _, e := range extractors {
+		if e.SeriesURLs == nil {
+			continue
+		}
+		if series, ok := e.SeriesURLs(pageURL, body); ok {
+			return series, e.Name
+		}
+	}
+	return make(map[string]string), ""
+}
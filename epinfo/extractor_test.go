@@ -0,0 +1,346 @@
+package epinfo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const showURL = "https://www.bbc.co.uk/iplayer/episodes/b0000001/example-show"
+
+// fixture returns the parsed HTML of a page saved in testdata.
+func fixture(t *testing.T, name string) *html.Node {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	body, err := html.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// fakeFetcher serves saved pages instead of fetching them.
+type fakeFetcher struct {
+	t     *testing.T
+	pages map[string]string
+	found []string
+	err   error
+}
+
+func (f *fakeFetcher) Fetch(pageURL string) (*html.Node, error) {
+	name, ok := f.pages[pageURL]
+	if !ok {
+		err := fmt.Errorf("not found: %s", pageURL)
+		f.Fail(err)
+		return nil, err
+	}
+	return fixture(f.t, name), nil
+}
+
+func (f *fakeFetcher) Found(pageURL string, episodes []EpisodeInfo) {
+	f.found = append(f.found, pageURL)
+}
+
+func (f *fakeFetcher) Fail(err error) {
+	if f.err == nil {
+		f.err = err
+	}
+}
+
+// urls returns the URLs of the episodes.
+func urls(episodes []EpisodeInfo) []string {
+	found := []string{}
+	for _, ep := range episodes {
+		found = append(found, ep.URL)
+	}
+	return found
+}
+
+func TestExtractorEpisodes(t *testing.T) {
+	page2 := showURL + "?page=2"
+	tests := []struct {
+		name      string
+		extractor func(Fetcher, string, *html.Node, bool, bool) ([]EpisodeInfo, bool)
+		first     string
+		pages     map[string]string
+		ad, sign  bool
+		want      []string
+		wantOK    bool
+		wantErr   bool
+	}{
+		{
+			name:      "state over two pages",
+			extractor: stateSeriesEpisodes,
+			first:     "state_page1.html",
+			pages:     map[string]string{page2: "state_page2.html"},
+			want: []string{
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/example-show-series-1-episode-2",
+				"https://www.bbc.co.uk/iplayer/episode/p0000003/example-show-series-1-episode-3",
+			},
+			wantOK: true,
+		},
+		{
+			name:      "state with variants",
+			extractor: stateSeriesEpisodes,
+			first:     "state_page1.html",
+			pages:     map[string]string{page2: "state_page2.html"},
+			ad:        true,
+			sign:      true,
+			want: []string{
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/ad/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/example-show-series-1-episode-2",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/sign/example-show-series-1-episode-2",
+				"https://www.bbc.co.uk/iplayer/episode/p0000003/example-show-series-1-episode-3",
+			},
+			wantOK: true,
+		},
+		{
+			name:      "state without a later page",
+			extractor: stateSeriesEpisodes,
+			first:     "state_page1.html",
+			pages:     map[string]string{},
+			want: []string{
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/example-show-series-1-episode-2",
+			},
+			wantOK:  true,
+			wantErr: true,
+		},
+		{
+			name:      "state unreadable on a later page",
+			extractor: stateSeriesEpisodes,
+			first:     "state_page1.html",
+			pages:     map[string]string{page2: "unknown.html"},
+			want: []string{
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/example-show-series-1-episode-2",
+			},
+			wantOK:  true,
+			wantErr: true,
+		},
+		{
+			name:      "state without episodes",
+			extractor: stateSeriesEpisodes,
+			first:     "state_empty.html",
+			want:      []string{},
+		},
+		{
+			name:      "links over two pages",
+			extractor: htmlSeriesEpisodes,
+			first:     "links_page1.html",
+			pages:     map[string]string{page2: "links_page2.html"},
+			want: []string{
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/example-show-series-1-episode-2",
+			},
+			wantOK: true,
+		},
+		{
+			name:      "links with variants",
+			extractor: htmlSeriesEpisodes,
+			first:     "links_page1.html",
+			pages:     map[string]string{page2: "links_page2.html"},
+			ad:        true,
+			sign:      true,
+			want: []string{
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/ad/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/sign/example-show-series-1-episode-2",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/example-show-series-1-episode-2",
+			},
+			wantOK: true,
+		},
+		{
+			name:      "links on an unknown page",
+			extractor: htmlSeriesEpisodes,
+			first:     "unknown.html",
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := &fakeFetcher{t: t, pages: tt.pages}
+			episodes, ok := tt.extractor(fetcher, showURL, fixture(t, tt.first), tt.ad, tt.sign)
+			if ok != tt.wantOK {
+				t.Errorf("recognised = %v, want %v", ok, tt.wantOK)
+			}
+			if got := urls(episodes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("episodes = %q, want %q", got, tt.want)
+			}
+			if (fetcher.err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", fetcher.err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStateEpisodeFields(t *testing.T) {
+	episodes, ok := stateSeriesEpisodes(&fakeFetcher{t: t}, showURL, fixture(t, "state_page1.html"), false, false)
+	if !ok || len(episodes) == 0 {
+		t.Fatal("state not recognised")
+	}
+	ep := episodes[0]
+	if *ep.TvShow != "Example Show" || ep.Series != "Series 1" || ep.Label != "Example Show, Series 1: Episode 1" {
+		t.Errorf("got show %q, series %q, label %q", *ep.TvShow, ep.Series, ep.Label)
+	}
+	if ep.SeriesNo != 1 || ep.EpisodeNo != 1 {
+		t.Errorf("got numbers %d, %d, want 1, 1", ep.SeriesNo, ep.EpisodeNo)
+	}
+}
+
+func TestParseEpisodesPage(t *testing.T) {
+	base, _ := url.Parse(showURL)
+	tests := []struct {
+		page       string
+		want       []string
+		wantLayout bool
+	}{
+		{
+			page: "state_page1.html",
+			want: []string{
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/ad/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/example-show-series-1-episode-2",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/sign/example-show-series-1-episode-2",
+			},
+		},
+		{
+			// The state lists no episode, the links of the page are read instead.
+			page: "state_empty.html",
+			want: []string{"https://www.bbc.co.uk/iplayer/episode/p0000001/example-show-series-1-episode-1"},
+		},
+		{
+			page: "links_page1.html",
+			want: []string{
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000001/ad/example-show-series-1-episode-1",
+				"https://www.bbc.co.uk/iplayer/episode/p0000002/sign/example-show-series-1-episode-2",
+			},
+		},
+		{page: "unknown.html", wantLayout: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			episodes, err := ParseEpisodesPage(f, base)
+			var layoutErr *LayoutError
+			if errors.As(err, &layoutErr) != tt.wantLayout {
+				t.Fatalf("error = %v, want layout error %v", err, tt.wantLayout)
+			}
+			if tt.wantLayout {
+				return
+			}
+			if got := urls(episodes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("episodes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSeriesNav(t *testing.T) {
+	base, _ := url.Parse(showURL)
+	tests := []struct {
+		page string
+		want map[string]string
+	}{
+		{
+			page: "state_page1.html",
+			want: map[string]string{"Series 1": showURL, "Series 2": showURL + "?seriesId=s02"},
+		},
+		{
+			page: "links_page1.html",
+			want: map[string]string{"Series 1": showURL + "?seriesId=s01", "Series 2": showURL + "?seriesId=s02"},
+		},
+		{page: "unknown.html", want: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			series, err := ParseSeriesNav(f, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(series, tt.want) {
+				t.Errorf("series = %v, want %v", series, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSeriesNavMarkup(t *testing.T) {
+	base, _ := url.Parse(showURL)
+	tests := []struct {
+		name    string
+		html    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "text right in the link",
+			html: `<a class="series-nav__button" href="?seriesId=p0000001">Series 1</a>`,
+			want: map[string]string{"Series 1": showURL + "?seriesId=p0000001"},
+		},
+		{
+			name: "empty link",
+			html: `<a class="series-nav__button" href="?seriesId=p0000001"></a>`,
+			want: map[string]string{},
+		},
+		{
+			name: "empty current series",
+			html: `<span class="series-nav__button"></span>`,
+			want: map[string]string{},
+		},
+		{
+			name: "current series",
+			html: `<span class="series-nav__button"> <b>Series 2</b> </span>`,
+			want: map[string]string{"Series 2": showURL},
+		},
+		{
+			name: "conflicting links",
+			html: `<a class="series-nav__button" href="?seriesId=p0000001">Series 1</a>
+<a class="series-nav__button" href="?seriesId=p0000002">Series 1</a>`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series, err := ParseSeriesNav(strings.NewReader(tt.html), base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(series, tt.want) {
+				t.Errorf("series = %v, want %v", series, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWithoutBase(t *testing.T) {
+	if _, err := ParseEpisodesPage(strings.NewReader("<html></html>"), nil); !errors.Is(err, ErrNoBase) {
+		t.Errorf("ParseEpisodesPage error = %v, want %v", err, ErrNoBase)
+	}
+	if _, err := ParseSeriesNav(strings.NewReader("<html></html>"), nil); !errors.Is(err, ErrNoBase) {
+		t.Errorf("ParseSeriesNav error = %v, want %v", err, ErrNoBase)
+	}
+}
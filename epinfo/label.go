@@ -0,0 +1,47 @@
+package epinfo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	seriesNoRe  = regexp.MustCompile(`(?i)\bseries (\d+)\b`)
+	episodeNoRe = regexp.MustCompile(`(?i)(?:^|[:,]\s*)(\d+)\.\s|\bepisode (\d+)\b`)
+	slashDateRe = regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{4})\b`)
+	longDateRe  = regexp.MustCompile(`\b(\d{1,2}) ([A-Z][a-z]+) (\d{4})\b`)
+)
+
+// labelNumbers reads the series and episode numbers from labels like
+// "Series 2", "Show, Series 2, 3. Title" or "Episode 3".
+func labelNumbers(series string, label string) (int, int) {
+	seriesNo, episodeNo := 0, 0
+	if m := seriesNoRe.FindStringSubmatch(series); m != nil {
+		seriesNo, _ = strconv.Atoi(m[1])
+	} else if m := seriesNoRe.FindStringSubmatch(label); m != nil {
+		seriesNo, _ = strconv.Atoi(m[1])
+	}
+	if m := episodeNoRe.FindStringSubmatch(label); m != nil {
+		episodeNo, _ = strconv.Atoi(m[1] + m[2])
+	}
+	return seriesNo, episodeNo
+}
+
+// labelDate reads dates like "19/03/2021" or "19 March 2021" from episode labels of daily shows.
+func labelDate(label string) time.Time {
+	if m := slashDateRe.FindStringSubmatch(label); m != nil {
+		if t, err := time.Parse("2/1/2006", fmt.Sprintf("%s/%s/%s", m[1], m[2], m[3])); err == nil {
+			return t
+		}
+	}
+	if m := longDateRe.FindStringSubmatch(label); m != nil {
+		for _, layout := range []string{"2 January 2006", "2 Jan 2006"} {
+			if t, err := time.Parse(layout, m[0]); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
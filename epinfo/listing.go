@@ -0,0 +1,179 @@
+package epinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gandalf15/iplayerlinks/ipurl"
+	"golang.org/x/net/html"
+)
+
+// Show is a programme linked from a category or A-Z listing.
+// URL is an episodes list, or an episode for one-off programmes.
+type Show struct {
+	Title, URL string
+}
+
+// ShowEpisodes holds the result of scraping one show of a listing.
+// SeriesURLs is the page of each series, see Diagnostics.
+type ShowEpisodes struct {
+	Show
+	Series     map[string][]EpisodeInfo
+	SeriesURLs map[string]string
+	Err        error
+}
+
+// nodeText returns the text inside node with whitespace collapsed.
+func nodeText(node *html.Node) string {
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(node)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// parseListing returns the shows and the links to other pages found on one page of listing.
+func parseListing(listing *ipurl.URL, pageURL string, body *html.Node) ([]Show, []string) {
+	shows := []Show{}
+	pageLinks := []string{}
+	var f func(*html.Node)
+	f = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "a" {
+			href := ""
+			title := ""
+			for _, attr := range node.Attr {
+				switch attr.Key {
+				case "href":
+					href = attr.Val
+				case "aria-label":
+					title = attr.Val
+				}
+			}
+			if u, err := ipurl.Parse(ipurl.Resolve(pageURL, href)); err == nil {
+				if title == "" {
+					title = nodeText(node)
+				}
+				switch u.Kind {
+				case ipurl.Episodes, ipurl.Series:
+					shows = append(shows, Show{title, u.ListURL()})
+				case ipurl.Episode:
+					u.Variant = ""
+					shows = append(shows, Show{title, u.String()})
+				case ipurl.Listing, ipurl.Search:
+					if u.Page > 0 && u.ListURL() == listing.ListURL() {
+						pageLinks = append(pageLinks, u.String())
+					}
+				}
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(body)
+	return shows, pageLinks
+}
+
+// ListingShows returns the shows linked from a category, A-Z or channel A-Z page and its other pages.
+// Each show is listed once, in the order it was first found.
+func ListingShows(pageURL string) ([]Show, error) {
+	return listingShows(background(), pageURL)
+}
+
+func listingShows(s *session, pageURL string) ([]Show, error) {
+	u, err := ipurl.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Kind != ipurl.Listing {
+		return nil, fmt.Errorf("not a category or A-Z URL: %s", pageURL)
+	}
+	u.Page = 1
+	pageVisited := map[string]bool{u.ListURL(): true, u.String(): true}
+	seen := map[string]bool{}
+	shows := []Show{}
+	queue := []string{u.ListURL()}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		body, err := s.Fetch(next)
+		if err != nil {
+			return shows, err
+		}
+		pageShows, pageLinks := parseListing(u, next, body)
+		for _, show := range pageShows {
+			if !seen[show.URL] {
+				seen[show.URL] = true
+				shows = append(shows, show)
+			}
+		}
+		for _, l := range pageLinks {
+			if !pageVisited[l] {
+				pageVisited[l] = true
+				queue = append(queue, l)
+			}
+		}
+	}
+	return shows, nil
+}
+
+// CrawlListing scrapes every show linked from a category, A-Z or channel A-Z page.
+// Shows are scraped at the same time, use SetMaxConcurrentFetches to cap the load on iPlayer.
+// The result is sorted by show title. Errors of single shows are kept in their ShowEpisodes.
+func CrawlListing(pageURL string, audioDescribed bool, signLang bool) ([]ShowEpisodes, error) {
+	return CrawlListingContext(context.Background(), pageURL, audioDescribed, signLang, nil)
+}
+
+// CrawlListingContext works like CrawlListing but stops fetching pages once ctx is cancelled
+// and calls onProgress, if not nil, with the counts of all shows together.
+// When ctx is cancelled the shows scraped so far are returned with the error of ctx.
+func CrawlListingContext(ctx context.Context, pageURL string, audioDescribed bool, signLang bool,
+	onProgress func(Progress)) ([]ShowEpisodes, error) {
+	return crawlListing(newSession(ctx, onProgress), pageURL, audioDescribed, signLang)
+}
+
+func crawlListing(sess *session, pageURL string, audioDescribed bool, signLang bool) ([]ShowEpisodes, error) {
+	shows, err := listingShows(sess, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ShowEpisodes, len(shows))
+	var wg sync.WaitGroup
+	for i, s := range shows {
+		wg.Add(1)
+		go func(i int, s Show) {
+			defer wg.Done()
+			res := ShowEpisodes{Show: s}
+			// Each show keeps its own error, a failed fetch of one show is not the error of the others.
+			showSess := sess.child()
+			var diag Diagnostics
+			res.Series, diag, res.Err = allEpisodes(showSess, s.URL, audioDescribed, signLang)
+			res.SeriesURLs = diag.SeriesURLs
+			if errors.Is(res.Err, ErrNoParent) {
+				// One-off programmes have no episodes list.
+				var episodes []EpisodeInfo
+				episodes, res.Err = singleEpisode(showSess, s.URL, audioDescribed, signLang)
+				res.Series = map[string][]EpisodeInfo{"none": episodes}
+				res.SeriesURLs = map[string]string{"none": s.URL}
+			}
+			results[i] = res
+		}(i, s)
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(i, j int) bool {
+		return strings.ToLower(results[i].Title) < strings.ToLower(results[j].Title)
+	})
+	return results, sess.ctx.Err()
+}
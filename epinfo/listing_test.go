@@ -0,0 +1,143 @@
+package epinfo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gandalf15/iplayerlinks/ipurl"
+)
+
+const listingURL = "https://www.bbc.co.uk/iplayer/a-z/e"
+
+func TestCrawlListingKeepsErrorsPerShow(t *testing.T) {
+	servePages(t, map[string]string{
+		"/iplayer/a-z/e":                          "listing_page1.html",
+		"/iplayer/a-z/e?page=2":                   "listing_page2.html",
+		"/iplayer/episodes/b0000001/example-show": "show_example.html",
+		"/iplayer/episodes/b0000002/another-show": "show_example.html",
+	}, "/iplayer/episodes/b0000009/broken-show")
+	results, err := CrawlListingContext(context.Background(), listingURL, false, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{}
+	for _, res := range results {
+		titles = append(titles, res.Title)
+		episodes := 0
+		for _, series := range res.Series {
+			episodes += len(series)
+		}
+		switch res.Title {
+		case "Broken Show":
+			if res.Err == nil {
+				t.Errorf("%s: no error", res.Title)
+			}
+		default:
+			if res.Err != nil || episodes != 2 {
+				t.Errorf("%s: got %d episodes and error %v, want 2 episodes", res.Title, episodes, res.Err)
+			}
+		}
+	}
+	if want := []string{"Another Show", "Broken Show", "Example Show"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("shows = %q, want %q", titles, want)
+	}
+}
+
+func TestParseListing(t *testing.T) {
+	u, err := ipurl.Parse(listingURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		page      string
+		wantShows []Show
+		wantPages []string
+	}{
+		{
+			page: "listing_page1.html",
+			wantShows: []Show{
+				{"Example Show", "https://www.bbc.co.uk/iplayer/episodes/b0000001/example-show"},
+				{"Broken Show", "https://www.bbc.co.uk/iplayer/episodes/b0000009/broken-show"},
+				{"Example Show", "https://www.bbc.co.uk/iplayer/episodes/b0000001/example-show"},
+			},
+			wantPages: []string{listingURL + "?page=2"},
+		},
+		{
+			page:      "listing_page2.html",
+			wantShows: []Show{{"Another Show", "https://www.bbc.co.uk/iplayer/episodes/b0000002/another-show"}, {"Example Show", "https://www.bbc.co.uk/iplayer/episodes/b0000001/example-show"}},
+			wantPages: []string{listingURL + "?page=1"},
+		},
+		{page: "unknown.html", wantShows: []Show{}, wantPages: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			pageURL := listingURL
+			shows, pages := parseListing(u, pageURL, fixture(t, tt.page))
+			if !reflect.DeepEqual(shows, tt.wantShows) {
+				t.Errorf("shows = %q, want %q", shows, tt.wantShows)
+			}
+			if !reflect.DeepEqual(pages, tt.wantPages) {
+				t.Errorf("pages = %q, want %q", pages, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestListingShows(t *testing.T) {
+	tests := []struct {
+		name    string
+		pageURL string
+		pages   map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "two pages",
+			pageURL: listingURL,
+			pages:   map[string]string{"/iplayer/a-z/e": "listing_page1.html", "/iplayer/a-z/e?page=2": "listing_page2.html"},
+			want:    []string{"Example Show", "Broken Show", "Another Show"},
+		},
+		{
+			name:    "later page missing",
+			pageURL: listingURL,
+			pages:   map[string]string{"/iplayer/a-z/e": "listing_page1.html"},
+			want:    []string{"Example Show", "Broken Show"},
+			wantErr: true,
+		},
+		{
+			name:    "not a listing",
+			pageURL: showURL,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servePages(t, tt.pages)
+			shows, err := ListingShows(tt.pageURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			titles := []string{}
+			for _, show := range shows {
+				titles = append(titles, show.Title)
+			}
+			if tt.want == nil {
+				tt.want = []string{}
+			}
+			if !reflect.DeepEqual(titles, tt.want) {
+				t.Errorf("shows = %q, want %q", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawlListingPartialFailure(t *testing.T) {
+	servePages(t, map[string]string{
+		"/iplayer/a-z/e": "listing_page1.html",
+		"/iplayer/episodes/b0000001/example-show": "show_example.html",
+	})
+	if _, err := CrawlListing(listingURL, false, false); err == nil {
+		t.Error("no error for a listing page that cannot be fetched")
+	}
+}
@@ -0,0 +1,114 @@
+package epinfo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// imageRecipe is the size asked for when an ichef image URL leaves it open.
+const imageRecipe = "1920x1080"
+
+// Metadata describes a show or an episode for media servers.
+// Image is the URL of its artwork, empty if the page has none.
+type Metadata struct {
+	Title, Synopsis, Image string
+}
+
+// PageMetadata reads the title, the synopsis and the artwork of a show or an episode page.
+// The page state is preferred, the Open Graph tags fill in what it lacks.
+func PageMetadata(pageURL string) (Metadata, error) {
+	return PageMetadataContext(context.Background(), pageURL)
+}
+
+// PageMetadataContext works like PageMetadata but abandons the page once ctx is cancelled.
+func PageMetadataContext(ctx context.Context, pageURL string) (Metadata, error) {
+	_, body, err := fetchPage(ctx, pageURL)
+	if err != nil {
+		return Metadata{}, err
+	}
+	m := Metadata{}
+	if state, ok := findState(body); ok && state.Episode.ID != "" {
+		m.Title = state.Episode.Title
+		if state.Episode.Subtitle != "" {
+			m.Title = state.Episode.Subtitle
+		}
+		m.Synopsis = state.Episode.synopsis()
+		m.Image = state.Episode.Images.Standard
+	}
+	var f func(*html.Node)
+	f = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "meta" {
+			attrs := map[string]string{}
+			for _, attr := range node.Attr {
+				attrs[attr.Key] = attr.Val
+			}
+			switch {
+			case attrs["property"] == "og:title" && m.Title == "":
+				m.Title = attrs["content"]
+			case (attrs["property"] == "og:description" || attrs["name"] == "description") && m.Synopsis == "":
+				m.Synopsis = attrs["content"]
+			case attrs["property"] == "og:image" && m.Image == "":
+				m.Image = attrs["content"]
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(body)
+	m.Image = strings.Replace(m.Image, "{recipe}", imageRecipe, 1)
+	return m, nil
+}
+
+// imageExts are the file extensions of the image types iPlayer serves artwork in.
+var imageExts = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// Artwork returns the image at imageURL, as found in a Metadata, with the file extension of its type.
+// The type is taken from the Content-Type of the response, or from the image itself when that is not an image type.
+// The request is abandoned once ctx is cancelled.
+func Artwork(ctx context.Context, imageURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := currentClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s: %s", imageURL, resp.Status)
+	}
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	ext, err := imageExt(resp.Header.Get("Content-Type"), raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", imageURL, err)
+	}
+	return raw, ext, nil
+}
+
+// imageExt returns the file extension of an image of contentType.
+func imageExt(contentType string, raw []byte) (string, error) {
+	for _, t := range []string{contentType, http.DetectContentType(raw)} {
+		if mediaType, _, err := mime.ParseMediaType(t); err == nil {
+			if ext, ok := imageExts[mediaType]; ok {
+				return ext, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("not an image: %s", contentType)
+}
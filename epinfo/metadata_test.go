@@ -0,0 +1,55 @@
+package epinfo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// pngHeader is enough of a PNG file for its type to be detected.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestImageExt(t *testing.T) {
+	tests := []struct {
+		contentType string
+		raw         []byte
+		want        string
+		wantErr     bool
+	}{
+		{contentType: "image/jpeg", want: ".jpg"},
+		{contentType: "image/png; charset=binary", want: ".png"},
+		{contentType: "image/webp", want: ".webp"},
+		{contentType: "application/octet-stream", raw: pngHeader, want: ".png"},
+		{contentType: "", raw: []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), want: ".jpg"},
+		{contentType: "text/html", raw: []byte("<html><body>Not found</body></html>"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			got, err := imageExt(tt.contentType, tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArtwork(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngHeader)
+	}))
+	defer server.Close()
+	raw, ext, err := Artwork(context.Background(), server.URL)
+	if err != nil || ext != ".png" || string(raw) != string(pngHeader) {
+		t.Errorf("got %d bytes, %q, %v", len(raw), ext, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := Artwork(ctx, server.URL); err == nil {
+		t.Error("cancelled request succeeded")
+	}
+}
@@ -0,0 +1,103 @@
+package epinfo
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/ipurl"
+	"golang.org/x/net/html"
+)
+
+// Page holds what PageParser found on a single episodes page.
+// Recognised is true if any episode link was seen, even if its variant was not wanted.
+type Page struct {
+	TvShow     string
+	Episodes   []EpisodeInfo
+	PageLinks  []string
+	Recognised bool
+}
+
+// PageParser reads episodes, links to other pages and the show title from iPlayer HTML.
+// Episodes of all pages parsed by the same parser share one TvShow,
+// so a title found on any page is seen by all of them.
+type PageParser struct {
+	AudioDescribed, SignLang bool
+	tvShow                   string
+}
+
+// NewPageParser returns a parser that keeps audio described and sign language links if asked to.
+func NewPageParser(audioDescribed bool, signLang bool) *PageParser {
+	return &PageParser{AudioDescribed: audioDescribed, SignLang: signLang}
+}
+
+// TvShow returns the show title found so far.
+func (p *PageParser) TvShow() string {
+	return p.tvShow
+}
+
+// Parse walks body, the parsed HTML of pageURL, in depth-first order.
+// Links to other pages of the same list are returned as absolute URLs.
+func (p *PageParser) Parse(pageURL string, body *html.Node) Page {
+	page := Page{}
+	var f func(*html.Node)
+	f = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "a":
+				p.anchor(pageURL, node, &page)
+			case "h1":
+				if page.TvShow == "" && node.FirstChild != nil && node.FirstChild.Type == html.TextNode {
+					for _, attr := range node.Attr {
+						if attr.Key == "class" && strings.Contains(attr.Val, "title") {
+							page.TvShow = node.FirstChild.Data
+						}
+					}
+				}
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(body)
+	if page.TvShow != "" && p.tvShow == "" {
+		p.tvShow = page.TvShow
+	}
+	return page
+}
+
+// anchor records the episode or page link of a single "a" element.
+func (p *PageParser) anchor(pageURL string, node *html.Node, page *Page) {
+	href := ""
+	label := ""
+	series := "none"
+	for _, attr := range node.Attr {
+		switch attr.Key {
+		case "href":
+			if strings.Contains(attr.Val, "/iplayer/episode/") {
+				href = ipurl.Resolve(pageURL, attr.Val)
+			} else if u, err := url.Parse(attr.Val); err == nil && u.Query().Get("page") != "" {
+				page.PageLinks = append(page.PageLinks, withQuery(pageURL, "page", u.Query().Get("page")))
+			}
+		case "aria-label":
+			label = attr.Val
+		case "data-bbc-container":
+			series = attr.Val
+		}
+	}
+	if href == "" || label == "" || series == "contextual-cta" {
+		return
+	}
+	page.Recognised = true
+	if strings.Contains(href, "/ad/") {
+		if p.AudioDescribed {
+			page.Episodes = append(page.Episodes, newEpisode(&p.tvShow, label, series, href, true, false))
+		}
+	} else if strings.Contains(href, "/sign/") {
+		if p.SignLang {
+			page.Episodes = append(page.Episodes, newEpisode(&p.tvShow, label, series, href, false, true))
+		}
+	} else {
+		page.Episodes = append(page.Episodes, newEpisode(&p.tvShow, label, series, href, false, false))
+	}
+}
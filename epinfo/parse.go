@@ -0,0 +1,59 @@
+package epinfo
+
+import (
+	"errors"
+	"io"
+	"net/url"
+
+	"golang.org/x/net/html"
+)
+
+// ErrNoBase is returned when the page to parse has no base URL.
+var ErrNoBase = errors.New("no base URL for the page")
+
+// htmlPage returns the episodes of all variants linked from a single page.
+func htmlPage(pageURL string, body *html.Node) ([]EpisodeInfo, bool) {
+	page := NewPageParser(true, true).Parse(pageURL, body)
+	return page.Episodes, page.Recognised
+}
+
+// ParseEpisodesPage returns the episodes found in HTML that was fetched from base, which must not be nil.
+// Unlike SeriesEpisodes, other pages of the list are not fetched and all variants are kept,
+// use the AudioDescribed and SignLang fields to filter them.
+// A *LayoutError is returned when no extractor recognises the page.
+func ParseEpisodesPage(r io.Reader, base *url.URL) ([]EpisodeInfo, error) {
+	if base == nil {
+		return nil, ErrNoBase
+	}
+	body, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range extractors {
+		if e.Page == nil {
+			continue
+		}
+		if episodes, ok := e.Page(base.String(), body); ok {
+			return episodes, nil
+		}
+	}
+	return nil, &LayoutError{URL: base.String(), Tried: Extractors()}
+}
+
+// ParseSeriesNav returns the series links found in HTML that was fetched from base,
+// in the same form as SeriesURLs. The series the page shows is mapped to base.
+// An empty map means the page has no series navigation, an error that a series is linked to two pages.
+func ParseSeriesNav(r io.Reader, base *url.URL) (map[string]string, error) {
+	if base == nil {
+		return nil, ErrNoBase
+	}
+	body, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	series, _, err := seriesNav(base.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
@@ -0,0 +1,36 @@
+package epinfo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gandalf15/iplayerlinks/ipurl"
+)
+
+// Search returns the shows found by the iPlayer search for query, best match first.
+// Only the first page of results is read.
+func Search(query string) ([]Show, error) {
+	return SearchContext(context.Background(), query)
+}
+
+// SearchContext works like Search but abandons the request once ctx is cancelled.
+func SearchContext(ctx context.Context, query string) ([]Show, error) {
+	u := ipurl.SearchURL(query)
+	if u.Query == "" {
+		return nil, errors.New("empty search query")
+	}
+	body, err := newSession(ctx, nil).Fetch(u.String())
+	if err != nil {
+		return nil, err
+	}
+	found, _ := parseListing(u, u.String(), body)
+	seen := map[string]bool{}
+	shows := []Show{}
+	for _, s := range found {
+		if !seen[s.URL] {
+			seen[s.URL] = true
+			shows = append(shows, s)
+		}
+	}
+	return shows, nil
+}
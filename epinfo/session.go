@@ -0,0 +1,134 @@
+package epinfo
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Progress counts what a scrape has found so far.
+type Progress struct {
+	PagesFetched, SeriesFound, EpisodesFound int
+}
+
+// Fetcher fetches the other pages of a list for the extractors.
+// Fetch fails once the scrape it belongs to is cancelled, its errors are kept as the error of the scrape.
+// Found reports the episodes of a page as soon as it is parsed.
+// Fail reports a page that was fetched but could not be read as the error of the scrape,
+// the episodes found so far are kept.
+type Fetcher interface {
+	Fetch(pageURL string) (*html.Node, error)
+	Found(pageURL string, episodes []EpisodeInfo)
+	Fail(err error)
+}
+
+// session is a single scrape, its pages are fetched under ctx.
+// Everything it finds is sent to onEvent and counted in its progress, both callbacks may be nil
+// and are called from the goroutines of the scrape.
+// A child session reports to its parent but keeps its own error, see child.
+type session struct {
+	ctx        context.Context
+	onEvent    func(Event)
+	onProgress func(Progress)
+	parent     *session
+	mu         sync.Mutex
+	progress   Progress
+	err        error
+}
+
+func newSession(ctx context.Context, onProgress func(Progress)) *session {
+	return &session{ctx: ctx, onProgress: onProgress}
+}
+
+// child returns a session for one show of a crawl: it is cancelled with s and its events
+// and progress go to s, but its fetch errors stay its own.
+func (s *session) child() *session {
+	return &session{ctx: s.ctx, parent: s}
+}
+
+// fetch returns the raw bytes and the parsed HTML of a page, the first failure is kept in s.err.
+func (s *session) fetch(pageURL string) ([]byte, *html.Node, error) {
+	if err := s.ctx.Err(); err != nil {
+		s.fail(err)
+		return nil, nil, err
+	}
+	raw, body, err := fetchPage(s.ctx, pageURL)
+	if err != nil {
+		s.fail(err)
+		return nil, nil, err
+	}
+	s.emit(Event{Kind: PageFetched, URL: pageURL})
+	return raw, body, nil
+}
+
+// Fetch implements Fetcher.
+func (s *session) Fetch(pageURL string) (*html.Node, error) {
+	_, body, err := s.fetch(pageURL)
+	return body, err
+}
+
+// Found implements Fetcher.
+func (s *session) Found(pageURL string, episodes []EpisodeInfo) {
+	for _, ep := range episodes {
+		s.emit(Event{Kind: EpisodeFound, URL: pageURL, Series: ep.Series, Episode: ep})
+	}
+}
+
+// emit counts ev in the progress and reports both.
+func (s *session) emit(ev Event) {
+	if s.parent != nil {
+		s.parent.emit(ev)
+		return
+	}
+	s.mu.Lock()
+	switch ev.Kind {
+	case PageFetched:
+		s.progress.PagesFetched++
+	case SeriesFound:
+		s.progress.SeriesFound++
+	case EpisodeFound:
+		s.progress.EpisodesFound++
+	}
+	p := s.progress
+	s.mu.Unlock()
+	if s.onEvent != nil {
+		s.onEvent(ev)
+	}
+	if s.onProgress != nil {
+		s.onProgress(p)
+	}
+}
+
+// Fail implements Fetcher.
+func (s *session) Fail(err error) {
+	s.fail(err)
+}
+
+func (s *session) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+}
+
+// Err returns the first fetch error of the scrape.
+func (s *session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// pageReporter passes the pages of one series to the session
+// and remembers whether the extractor reported any of them itself.
+type pageReporter struct {
+	*session
+	reported bool
+}
+
+// Found implements Fetcher.
+func (r *pageReporter) Found(pageURL string, episodes []EpisodeInfo) {
+	r.reported = true
+	r.session.Found(pageURL, episodes)
+}
@@ -0,0 +1,233 @@
+package epinfo
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gandalf15/iplayerlinks/ipurl"
+	"golang.org/x/net/html"
+)
+
+// stateMarker identifies the inline script that holds the iPlayer page state.
+const stateMarker = "__IPLAYER_REDUX_STATE__"
+
+// pageState is the part of the embedded iPlayer state we care about.
+// Unknown keys are ignored so the page can grow without breaking us.
+type pageState struct {
+	Header struct {
+		Title           string       `json:"title"`
+		CurrentSliceID  string       `json:"currentSliceId"`
+		AvailableSlices []sliceState `json:"availableSlices"`
+	} `json:"header"`
+	Entities struct {
+		Results []struct {
+			Type    string       `json:"type"`
+			Episode episodeState `json:"episode"`
+		} `json:"results"`
+	} `json:"entities"`
+	Pagination struct {
+		CurrentPage int `json:"currentPage"`
+		TotalPages  int `json:"totalPages"`
+	} `json:"pagination"`
+	Episode episodeState `json:"episode"`
+}
+
+// sliceState is one entry of the series navigation.
+type sliceState struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// episodeState is a single episode as described by the page state.
+// TleoID is the brand the episode belongs to, it is only set on episode pages.
+// Image is an ichef URL with a {recipe} placeholder for its size.
+type episodeState struct {
+	ID             string `json:"id"`
+	TleoID         string `json:"tleoId"`
+	Title          string `json:"title"`
+	Subtitle       string `json:"subtitle"`
+	Slug           string `json:"slug"`
+	AudioDescribed bool   `json:"audioDescribed"`
+	SignLanguage   bool   `json:"signed"`
+	Synopses       struct {
+		Small  string `json:"small"`
+		Medium string `json:"medium"`
+		Large  string `json:"large"`
+	} `json:"synopses"`
+	Images struct {
+		Standard string `json:"standard"`
+	} `json:"images"`
+}
+
+// synopsis returns the longest synopsis of the episode.
+func (ep episodeState) synopsis() string {
+	for _, s := range []string{ep.Synopses.Large, ep.Synopses.Medium, ep.Synopses.Small} {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// findState looks for the embedded state script and decodes it.
+// It returns false when the page has no state or it cannot be decoded.
+func findState(body *html.Node) (*pageState, bool) {
+	var script string
+	var f func(*html.Node)
+	f = func(node *html.Node) {
+		if script != "" {
+			return
+		}
+		if node.Type == html.ElementNode && node.Data == "script" && node.FirstChild != nil {
+			if strings.Contains(node.FirstChild.Data, stateMarker) {
+				script = node.FirstChild.Data
+				return
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(body)
+	if script == "" {
+		return nil, false
+	}
+	script = script[strings.Index(script, stateMarker):]
+	start := strings.Index(script, "{")
+	if start == -1 {
+		return nil, false
+	}
+	// The decoder stops after the first JSON value, so a trailing ";" is fine.
+	state := &pageState{}
+	if err := json.NewDecoder(strings.NewReader(script[start:])).Decode(state); err != nil {
+		return nil, false
+	}
+	return state, true
+}
+
+// hasEpisodes reports whether the state lists any episode, whatever its variants.
+func (state *pageState) hasEpisodes() bool {
+	for _, r := range state.Entities.Results {
+		if r.Type == "episode" && r.Episode.ID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// currentSeries returns the title of the series the state was rendered for.
+func (state *pageState) currentSeries() string {
+	for _, s := range state.Header.AvailableSlices {
+		if s.ID == state.Header.CurrentSliceID {
+			return s.Title
+		}
+	}
+	return "none"
+}
+
+// episodes converts the episodes of the state to EpisodeInfo.
+func (state *pageState) episodes(pageURL string, tvShow *string, audioDescribed bool, signLang bool) []EpisodeInfo {
+	series := state.currentSeries()
+	episodes := []EpisodeInfo{}
+	for _, r := range state.Entities.Results {
+		if r.Type == "episode" {
+			episodes = append(episodes, r.Episode.variants(pageURL, tvShow, series, audioDescribed, signLang)...)
+		}
+	}
+	return episodes
+}
+
+// variants returns the standard episode followed by the wanted versions it is available in.
+func (ep episodeState) variants(pageURL string, tvShow *string, series string, audioDescribed bool, signLang bool) []EpisodeInfo {
+	if ep.ID == "" {
+		return nil
+	}
+	label := ep.Title
+	if ep.Subtitle != "" {
+		label += ", " + ep.Subtitle
+	}
+	episodes := []EpisodeInfo{newEpisode(tvShow, label, series, ep.url(pageURL, ""), false, false)}
+	if ep.AudioDescribed && audioDescribed {
+		episodes = append(episodes, newEpisode(tvShow, label, series, ep.url(pageURL, "ad"), true, false))
+	}
+	if ep.SignLanguage && signLang {
+		episodes = append(episodes, newEpisode(tvShow, label, series, ep.url(pageURL, "sign"), false, true))
+	}
+	return episodes
+}
+
+// url builds the episode link for the given variant ("" for the standard one).
+func (ep episodeState) url(pageURL string, variant string) string {
+	return ipurl.Resolve(pageURL, path.Join("/iplayer/episode", ep.ID, variant, ep.Slug))
+}
+
+// withQuery returns pageURL with the query parameter key set to value.
+func withQuery(pageURL string, key string, value string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return pageURL
+	}
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// stateSeriesEpisodes collects the episodes of every page of a series using the page state.
+// A state without any episode on the first page is not recognised, its layout may have changed.
+// A later page without a state is reported to fetcher and ends the series.
+func stateSeriesEpisodes(fetcher Fetcher, pageURL string, body *html.Node, audioDescribed bool, signLang bool) ([]EpisodeInfo, bool) {
+	state, ok := findState(body)
+	if !ok || !state.hasEpisodes() {
+		return nil, false
+	}
+	tvShow := state.Header.Title
+	episodes := state.episodes(pageURL, &tvShow, audioDescribed, signLang)
+	fetcher.Found(pageURL, episodes)
+	for page := 2; page <= state.Pagination.TotalPages; page++ {
+		nextURL := withQuery(pageURL, "page", strconv.Itoa(page))
+		nextBody, err := fetcher.Fetch(nextURL)
+		if err != nil {
+			break
+		}
+		nextState, ok := findState(nextBody)
+		if !ok {
+			fetcher.Fail(&LayoutError{URL: nextURL, Tried: []string{"page-state"}})
+			break
+		}
+		pageEpisodes := nextState.episodes(nextURL, &tvShow, audioDescribed, signLang)
+		fetcher.Found(nextURL, pageEpisodes)
+		episodes = append(episodes, pageEpisodes...)
+	}
+	return episodes, true
+}
+
+// statePage returns the episodes of all variants in the page state of a single page.
+func statePage(pageURL string, body *html.Node) ([]EpisodeInfo, bool) {
+	state, ok := findState(body)
+	if !ok || !state.hasEpisodes() {
+		return nil, false
+	}
+	tvShow := state.Header.Title
+	return state.episodes(pageURL, &tvShow, true, true), true
+}
+
+// stateSeriesURLs returns the series links listed in the page state.
+func stateSeriesURLs(pageURL string, body *html.Node) (map[string]string, bool) {
+	state, ok := findState(body)
+	if !ok || len(state.Header.AvailableSlices) == 0 {
+		return nil, false
+	}
+	series := make(map[string]string)
+	for _, s := range state.Header.AvailableSlices {
+		if s.ID == state.Header.CurrentSliceID {
+			series[s.Title] = pageURL
+		} else {
+			series[s.Title] = withQuery(pageURL, "seriesId", s.ID)
+		}
+	}
+	return series, true
+}
@@ -0,0 +1,68 @@
+package epinfo
+
+import (
+	"context"
+
+	"github.com/gandalf15/iplayerlinks/ipurl"
+)
+
+// EventKind tells what an Event reports.
+type EventKind int
+
+// Kinds of events sent by Stream
+const (
+	PageFetched  EventKind = iota // a page was fetched, URL is set
+	SeriesFound                   // a series was found, URL and Series are set
+	EpisodeFound                  // an episode was parsed, URL is the page it was found on
+	Done                          // the scrape is over, Err is set if it failed
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case PageFetched:
+		return "page"
+	case SeriesFound:
+		return "series"
+	case EpisodeFound:
+		return "episode"
+	case Done:
+		return "done"
+	}
+	return "unknown"
+}
+
+// Event is something a scrape found.
+type Event struct {
+	Kind    EventKind
+	URL     string
+	Series  string
+	Episode EpisodeInfo
+	Err     error
+}
+
+// Stream scrapes pageURL like AllEpisodesInfo, or like CrawlListing for listings,
+// and sends what it finds as soon as each page is parsed.
+// The last event is Done, then the channel is closed.
+// Read the channel until it is closed or cancel ctx, the scrape waits for its events to be read.
+func Stream(ctx context.Context, pageURL string, audioDescribed bool, signLang bool) <-chan Event {
+	ch := make(chan Event)
+	send := func(ev Event) {
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(ch)
+		s := newSession(ctx, nil)
+		s.onEvent = send
+		var err error
+		if u, parseErr := ipurl.Parse(pageURL); parseErr == nil && u.Kind == ipurl.Listing {
+			_, err = crawlListing(s, pageURL, audioDescribed, signLang)
+		} else {
+			_, _, err = allEpisodes(s, pageURL, audioDescribed, signLang)
+		}
+		send(Event{Kind: Done, Err: err})
+	}()
+	return ch
+}
@@ -0,0 +1,68 @@
+package epinfo
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// collect reads the events of ch until it is closed, failing the test when that takes too long.
+func collect(t *testing.T, ch <-chan Event) []Event {
+	t.Helper()
+	events := []Event{}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		case <-timeout:
+			t.Fatal("the channel was not closed")
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	servePages(t, map[string]string{"/iplayer/episodes/b0000001/example-show": "show_example.html"})
+	events := collect(t, Stream(context.Background(), showURL, false, false))
+	kinds := []EventKind{}
+	for _, ev := range events {
+		kinds = append(kinds, ev.Kind)
+	}
+	want := []EventKind{PageFetched, SeriesFound, EpisodeFound, EpisodeFound, Done}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("events = %v, want %v", kinds, want)
+	}
+	if events[0].URL != showURL || events[2].Episode.EpisodeNo != 1 || events[3].Episode.EpisodeNo != 2 {
+		t.Errorf("got events %+v", events)
+	}
+	if err := events[len(events)-1].Err; err != nil {
+		t.Errorf("done with error %v", err)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	p := servePages(t, map[string]string{})
+	p.hanging["/iplayer/episodes/b0000001/example-show"] = true
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := Stream(ctx, showURL, false, false)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	// The scrape stops without its events being read.
+	events := collect(t, ch)
+	if len(events) > 1 {
+		t.Errorf("got %d events after the cancellation", len(events))
+	}
+	p.idle()
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("%d goroutines left running, %d before the scrape", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,206 @@
+// Package filter selects scraped episodes by series, label, episode numbers, variant and date.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gandalf15/iplayerlinks/epinfo"
+)
+
+// DateLayout is the layout of dates in a Spec.
+const DateLayout = "2006-01-02"
+
+// Number identifies an episode, Series 0 matches any series.
+type Number struct {
+	Series, Episode int
+}
+
+// Range is an inclusive range of episode numbers.
+type Range struct {
+	From, To Number
+}
+
+// Filter selects episodes. Empty fields match every episode.
+// Episodes without a known number or date never match Ranges or From and To.
+type Filter struct {
+	Series   string
+	Label    string
+	LabelRe  *regexp.Regexp
+	Ranges   []Range
+	Variants []string
+	From, To time.Time
+}
+
+// Spec is the textual form of a Filter as typed on the command line or in the GUI.
+// Episodes is a comma separated list like "S2E3-S2E8,S3,E1-E4", Variants a list of
+// "standard", "ad" and "sign", From and To are dates like 2021-03-19.
+type Spec struct {
+	Series     string `yaml:"series"`
+	Label      string `yaml:"label"`
+	LabelRegex string `yaml:"label_regex"`
+	Episodes   string `yaml:"episodes"`
+	Variants   string `yaml:"variants"`
+	From       string `yaml:"from"`
+	To         string `yaml:"to"`
+}
+
+// Compile parses the spec into a Filter.
+func (s Spec) Compile() (*Filter, error) {
+	f := &Filter{Series: strings.TrimSpace(s.Series), Label: strings.TrimSpace(s.Label)}
+	var err error
+	if s.LabelRegex != "" {
+		if f.LabelRe, err = regexp.Compile(s.LabelRegex); err != nil {
+			return nil, fmt.Errorf("label regex: %w", err)
+		}
+	}
+	if f.Ranges, err = ParseRanges(s.Episodes); err != nil {
+		return nil, err
+	}
+	for _, v := range strings.Split(s.Variants, ",") {
+		v = strings.ToLower(strings.TrimSpace(v))
+		switch v {
+		case "":
+		case "standard", "ad", "sign":
+			f.Variants = append(f.Variants, v)
+		default:
+			return nil, fmt.Errorf("unknown variant %q, use standard, ad or sign", v)
+		}
+	}
+	if s.From != "" {
+		if f.From, err = time.Parse(DateLayout, strings.TrimSpace(s.From)); err != nil {
+			return nil, fmt.Errorf("from date: %w", err)
+		}
+	}
+	if s.To != "" {
+		if f.To, err = time.Parse(DateLayout, strings.TrimSpace(s.To)); err != nil {
+			return nil, fmt.Errorf("to date: %w", err)
+		}
+	}
+	return f, nil
+}
+
+var numberRe = regexp.MustCompile(`^(?i)(?:s(\d+))?(?:e?(\d+))?$`)
+
+// parseNumber reads "S2E3", "S2", "E3" or "3". A missing episode is 0 for the start of
+// a range and the last possible episode for its end.
+func parseNumber(s string, end bool) (Number, error) {
+	m := numberRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || (m[1] == "" && m[2] == "") {
+		return Number{}, fmt.Errorf("invalid episode number %q, use S2E3, S2 or E3", s)
+	}
+	n := Number{}
+	n.Series, _ = strconv.Atoi(m[1])
+	if m[2] == "" {
+		if end {
+			n.Episode = int(^uint(0) >> 1)
+		}
+	} else {
+		n.Episode, _ = strconv.Atoi(m[2])
+	}
+	return n, nil
+}
+
+// ParseRanges reads a comma separated list of episode numbers and ranges like "S2E3-S2E8,S3,E1-E4".
+func ParseRanges(s string) ([]Range, error) {
+	ranges := []Range{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to := part, part
+		if i := strings.Index(part, "-"); i != -1 {
+			from, to = part[:i], part[i+1:]
+		}
+		r := Range{}
+		var err error
+		if r.From, err = parseNumber(from, false); err != nil {
+			return nil, err
+		}
+		if r.To, err = parseNumber(to, true); err != nil {
+			return nil, err
+		}
+		if r.To.Series == 0 {
+			r.To.Series = r.From.Series
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// Contains reports whether the episode number is in the range.
+// Episodes without a series number are only in ranges that name no series.
+func (r Range) Contains(seriesNo int, episodeNo int) bool {
+	if episodeNo == 0 {
+		return false
+	}
+	if r.From.Series == 0 && r.To.Series == 0 {
+		return r.From.Episode <= episodeNo && episodeNo <= r.To.Episode
+	}
+	if seriesNo == 0 {
+		return false
+	}
+	from := r.From
+	if from.Series == 0 {
+		from.Series = seriesNo
+	}
+	after := seriesNo > from.Series || (seriesNo == from.Series && episodeNo >= from.Episode)
+	before := seriesNo < r.To.Series || (seriesNo == r.To.Series && episodeNo <= r.To.Episode)
+	return after && before
+}
+
+// Match reports whether the episode passes the filter.
+func (f *Filter) Match(ep epinfo.EpisodeInfo) bool {
+	if f.Series != "" && !strings.Contains(strings.ToLower(ep.Series), strings.ToLower(f.Series)) {
+		return false
+	}
+	if f.Label != "" && !strings.Contains(strings.ToLower(ep.Label), strings.ToLower(f.Label)) {
+		return false
+	}
+	if f.LabelRe != nil && !f.LabelRe.MatchString(ep.Label) {
+		return false
+	}
+	if len(f.Ranges) > 0 {
+		found := false
+		for _, r := range f.Ranges {
+			found = found || r.Contains(ep.SeriesNo, ep.EpisodeNo)
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Variants) > 0 {
+		found := false
+		for _, v := range f.Variants {
+			found = found || v == ep.Variant()
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.From.IsZero() && (ep.Aired.IsZero() || ep.Aired.Before(f.From)) {
+		return false
+	}
+	if !f.To.IsZero() && (ep.Aired.IsZero() || ep.Aired.After(f.To)) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the episodes of every series that pass the filter.
+// Series left without episodes are dropped.
+func (f *Filter) Apply(allSeries map[string][]epinfo.EpisodeInfo) map[string][]epinfo.EpisodeInfo {
+	res := make(map[string][]epinfo.EpisodeInfo)
+	for series, episodes := range allSeries {
+		for _, ep := range episodes {
+			if f.Match(ep) {
+				res[series] = append(res[series], ep)
+			}
+		}
+	}
+	return res
+}
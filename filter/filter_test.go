@@ -0,0 +1,74 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRanges(t *testing.T) {
+	last := int(^uint(0) >> 1)
+	tests := []struct {
+		spec    string
+		want    []Range
+		wantErr bool
+	}{
+		{spec: "", want: []Range{}},
+		{spec: "S2E3-S2E8", want: []Range{{Number{2, 3}, Number{2, 8}}}},
+		{spec: "s3", want: []Range{{Number{3, 0}, Number{3, last}}}},
+		{spec: "E1-E4, 7", want: []Range{{Number{0, 1}, Number{0, 4}}, {Number{0, 7}, Number{0, 7}}}},
+		{spec: "S1E5-E9", want: []Range{{Number{1, 5}, Number{1, 9}}}},
+		{spec: "S2-S3", want: []Range{{Number{2, 0}, Number{3, last}}}},
+		{spec: "X1", wantErr: true},
+		{spec: "S2E3-", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRanges(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeContains(t *testing.T) {
+	tests := []struct {
+		spec                string
+		seriesNo, episodeNo int
+		want                bool
+	}{
+		{"S2E3-S2E8", 2, 3, true},
+		{"S2E3-S2E8", 2, 8, true},
+		{"S2E3-S2E8", 2, 9, false},
+		{"S2E3-S2E8", 3, 5, false},
+		{"S2E3-S3E2", 2, 20, true},
+		{"S2E3-S3E2", 3, 1, true},
+		{"S2E3-S3E2", 3, 3, false},
+		{"S3", 3, 42, true},
+		{"S3", 4, 1, false},
+		{"E1-E4", 5, 2, true},
+		{"E1-E4", 0, 2, true},
+		{"E1-E4", 0, 5, false},
+		{"E3-S2E5", 1, 3, true},
+		{"E3-S2E5", 1, 2, false},
+		// Episodes without a series number are not in a range naming a series.
+		{"S2E3-S2E8", 0, 5, false},
+		{"S3", 0, 1, false},
+		{"E3-S2E5", 0, 4, false},
+		// Episodes without a number are in no range.
+		{"E1-E4", 0, 0, false},
+		{"S2", 2, 0, false},
+	}
+	for _, tt := range tests {
+		ranges, err := ParseRanges(tt.spec)
+		if err != nil || len(ranges) != 1 {
+			t.Fatalf("ParseRanges(%q) = %v, %v", tt.spec, ranges, err)
+		}
+		if got := ranges[0].Contains(tt.seriesNo, tt.episodeNo); got != tt.want {
+			t.Errorf("%s contains S%dE%d = %v, want %v", tt.spec, tt.seriesNo, tt.episodeNo, got, tt.want)
+		}
+	}
+}
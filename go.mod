@@ -5,4 +5,5 @@ go 1.14
 require (
 	fyne.io/fyne v1.4.2
 	golang.org/x/net v0.0.0-20201224014010-6772e930b67b
+	gopkg.in/yaml.v2 v2.2.8
 )
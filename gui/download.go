@@ -0,0 +1,253 @@
+package gui
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/dialog"
+	"fyne.io/fyne/widget"
+	"github.com/gandalf15/iplayerlinks/download"
+)
+
+// backend returns the downloader chosen in the settings with the arguments of the config
+// and the format of the chosen quality. The format of the config is used only while the chosen quality
+// is the one of the config, a warning tells when it replaces a quality other than best.
+// Extracted audio is always downloaded with the audio only profile.
+func (iplGUI *IPlayerLinksGUI) backend() (download.Backend, error) {
+	cfg := iplGUI.settings.cfg.Downloader
+	b := download.Backend{Program: iplGUI.settings.backend(), Format: cfg.Format, Args: cfg.Args}
+	q, err := download.ParseQuality(iplGUI.quality.Text)
+	if err != nil {
+		return b, err
+	}
+	iplGUI.settings.prefs.SetString(prefQuality, q.String())
+	if cfgQuality, err := download.ParseQuality(cfg.Quality); err != nil || q != cfgQuality {
+		b.Format = ""
+	} else if b.Format != "" && q.Name != download.Best {
+		log.Printf("Warning, the format %q of the config is used instead of the quality %s", b.Format, q)
+	}
+	b.SetHTTP(iplGUI.settings.http())
+	b.SetQuality(iplGUI.settings.audio().Quality(q))
+	return b, nil
+}
+
+// listFormats shows the formats the backend finds for the first selected episode.
+func (iplGUI *IPlayerLinksGUI) listFormats() {
+	episodes := iplGUI.episodes.Selected()
+	if len(episodes) == 0 {
+		dialog.NewError(errors.New("Select an episode first"), iplGUI.window).Show()
+		return
+	}
+	backend := download.Backend{Program: iplGUI.settings.backend(), Args: iplGUI.settings.cfg.Downloader.Args}
+	backend.SetHTTP(iplGUI.settings.http())
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := widget.NewMultiLineEntry()
+	entry.SetReadOnly(true)
+	scrollCont := container.NewScroll(entry)
+	scrollCont.SetMinSize(fyne.NewSize(600, 400))
+	d := dialog.NewCustom("Formats of "+episodes[0].Label, "Close", scrollCont, iplGUI.window)
+	d.SetOnClosed(cancel)
+	d.Show()
+	outReader, outWriter := io.Pipe()
+	go showOutput(outReader, entry, scrollCont)
+	go func() {
+		cmd := backend.ListFormats(ctx, episodes[0].URL)
+		cmd.Stdout = outWriter
+		cmd.Stderr = outWriter
+		if err := cmd.Run(); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(outWriter, "\n%s: %s\n", backend.Program, err)
+		}
+		outWriter.Close()
+	}()
+}
+
+func (iplGUI *IPlayerLinksGUI) downloadAllEpisodes() {
+	episodes := iplGUI.episodes.Selected()
+	if len(episodes) == 0 {
+		dialog.NewError(errors.New("Nothing to download"), iplGUI.window).Show()
+		return
+	}
+	tmpl, err := download.ParseTemplate(iplGUI.settings.template())
+	if err != nil {
+		dialog.NewError(err, iplGUI.window).Show()
+		return
+	}
+	if tmpl, err = iplGUI.settings.audio().Template(tmpl); err != nil {
+		dialog.NewError(err, iplGUI.window).Show()
+		return
+	}
+	if iplGUI.settings.verify() {
+		if err := tmpl.Resolvable(); err != nil {
+			dialog.NewError(err, iplGUI.window).Show()
+			return
+		}
+	}
+	backend, err := iplGUI.backend()
+	if err != nil {
+		dialog.NewError(err, iplGUI.window).Show()
+		return
+	}
+	f := func(uri fyne.ListableURI, err error) {
+		if err != nil {
+			log.Fatalf("Error while opening destination folder %s", err.Error())
+		}
+		if uri == nil {
+			return
+		}
+		iplGUI.destDir = strings.Replace(uri.String(), "file://", "", 1)
+		iplGUI.settings.prefs.SetString(prefDownloadDir, iplGUI.destDir)
+		iplGUI.preflight(backend, download.Jobs(episodes, iplGUI.destDir, tmpl))
+	}
+	d := dialog.NewFolderOpen(f, iplGUI.window)
+	if dir := iplGUI.settings.downloadLocation(); dir != nil {
+		d.SetLocation(dir)
+	}
+	d.Show()
+}
+
+// preflight checks in the background whether the jobs fit in the free space of the download folder
+// and warns or refuses as the settings say before running them.
+func (iplGUI *IPlayerLinksGUI) preflight(backend download.Backend, jobs []download.Job) {
+	check := iplGUI.settings.spaceCheck()
+	if check == download.SpaceOff {
+		iplGUI.runDownloads(backend, jobs)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := dialog.NewCustom("Checking Free Space", "Cancel", widget.NewProgressBarInfinite(), iplGUI.window)
+	d.SetOnClosed(cancel)
+	d.Show()
+	go func() {
+		p, err := backend.CheckSpace(ctx, jobs, iplGUI.destDir, iplGUI.settings.maxBytes())
+		if ctx.Err() != nil {
+			return
+		}
+		d.Hide()
+		switch {
+		case err != nil:
+			log.Printf("Cannot check the free space: %s", err)
+		case p.Short() && check == download.SpaceRefuse:
+			dialog.ShowError(fmt.Errorf("Not enough space in %s: %s", iplGUI.destDir, p), iplGUI.window)
+			return
+		case p.Short():
+			dialog.ShowConfirm("Not Enough Space", fmt.Sprintf("%s.\nDownload anyway?", p), func(ok bool) {
+				if ok {
+					iplGUI.runDownloads(backend, jobs)
+				}
+			}, iplGUI.window)
+			return
+		}
+		iplGUI.runDownloads(backend, jobs)
+	}()
+}
+
+// runDownloads downloads the jobs with the settings.
+func (iplGUI *IPlayerLinksGUI) runDownloads(backend download.Backend, jobs []download.Job) {
+	runner := &download.Runner{Backend: backend, Sidecars: iplGUI.settings.metadata(),
+		Subtitles: iplGUI.settings.subtitles(iplGUI.checks["subtitles"].Checked), Audio: iplGUI.settings.audio(),
+		Hooks: iplGUI.settings.hooks(), Verify: iplGUI.settings.verify(),
+		MaxBytes: iplGUI.settings.maxBytes(), Schedule: iplGUI.settings.schedule()}
+	iplGUI.run(runner, jobs, nil)
+}
+
+// run runs the jobs in the background showing the output of the backend.
+// The results of a retry are merged into the earlier ones of the batch, the same runner
+// keeps counting the storage cap of the batch. Cancel kills the running download and skips the others.
+func (iplGUI *IPlayerLinksGUI) run(runner *download.Runner, jobs []download.Job, earlier []download.Result) {
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := widget.NewMultiLineEntry()
+	entry.SetReadOnly(true)
+	scrollCont := container.NewScroll(entry)
+	scrollCont.SetMinSize(fyne.NewSize(600, 400))
+	downloadingCont := container.NewVBox(widget.NewProgressBarInfinite(), scrollCont)
+	d := dialog.NewCustom("Downloading", "Cancel", downloadingCont, iplGUI.window)
+	d.SetOnClosed(cancel)
+	d.Show()
+	outReader, outWriter := io.Pipe()
+	go showOutput(outReader, entry, scrollCont)
+	download.SetBandwidth(iplGUI.settings.bandwidth(), iplGUI.settings.cfg.RateLimit.MaxDownloads)
+	runner.Output = outWriter
+	go func() {
+		results := runner.Run(ctx, jobs)
+		if earlier != nil {
+			results = download.Merge(earlier, results)
+		}
+		batchErr := runner.Finish(ctx, results)
+		outWriter.Close()
+		if ctx.Err() != nil {
+			log.Println("Download cancelled")
+			return
+		}
+		d.Hide()
+		iplGUI.showReport(runner, results, batchErr)
+	}()
+}
+
+// showReport shows whether each episode passed, the failed ones can be downloaded again.
+func (iplGUI *IPlayerLinksGUI) showReport(runner *download.Runner, results []download.Result, batchErr error) {
+	failed := download.Failed(results)
+	hookFailed, skipped := batchErr != nil, false
+	for _, res := range results {
+		hookFailed = hookFailed || res.Hook != nil
+		skipped = skipped || res.Skipped != nil
+	}
+	if len(failed) == 0 && !hookFailed && !skipped {
+		dialog.NewInformation("Finished", "Success", iplGUI.window).Show()
+		return
+	}
+	var report strings.Builder
+	if len(failed) > 0 {
+		fmt.Fprintf(&report, "%d of %d downloads failed\n", len(failed), len(results))
+	}
+	download.Report(&report, results)
+	if batchErr != nil {
+		fmt.Fprintln(&report, batchErr)
+	}
+	log.Print(report.String())
+	text := widget.NewMultiLineEntry()
+	text.SetText(report.String())
+	text.SetReadOnly(true)
+	scrollCont := container.NewScroll(text)
+	scrollCont.SetMinSize(fyne.NewSize(600, 300))
+	if len(failed) == 0 {
+		title := "Downloads Skipped"
+		if hookFailed {
+			title = "Hooks Failed"
+		}
+		dialog.NewCustom(title, "Close", scrollCont, iplGUI.window).Show()
+		return
+	}
+	dialog.ShowCustomConfirm("Downloads Failed", "Retry Failed", "Close", scrollCont, func(retry bool) {
+		if retry {
+			iplGUI.run(runner, download.Requeue(results), results)
+		}
+	}, iplGUI.window)
+}
+
+// showOutput copies the output of the backend to entry, a carriage return overwrites the current line.
+func showOutput(r io.Reader, entry *widget.Entry, scrollCont *container.Scroll) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanRunes)
+	textOut := []string{}
+	lastNewLine := 0
+	for scanner.Scan() {
+		newText := scanner.Text()
+		if newText == "\r" {
+			textOut = textOut[:lastNewLine]
+			continue
+		}
+		textOut = append(textOut, newText)
+		if newText == "\n" {
+			lastNewLine = len(textOut)
+		}
+		entry.SetText(strings.Join(textOut, ""))
+		scrollCont.ScrollToBottom()
+	}
+}
@@ -0,0 +1,247 @@
+package gui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"fyne.io/fyne"
+	"fyne.io/fyne/container"
+	"fyne.io/fyne/layout"
+	"fyne.io/fyne/widget"
+	"github.com/gandalf15/iplayerlinks/epinfo"
+	"github.com/gandalf15/iplayerlinks/filter"
+)
+
+// episodeGroup is a series shown as a branch of the episode tree.
+// url is the page its episodes were read from.
+type episodeGroup struct {
+	title, url string
+	episodes   []int
+}
+
+// episodeTree lists the found episodes grouped by series with a check box for each of them.
+// Checking a series selects all its episodes.
+// The sidebar lists the series with their counts, clicking one shows only its episodes.
+type episodeTree struct {
+	tree      *widget.Tree
+	sidebar   *widget.List
+	groups    []episodeGroup
+	only      int
+	episodes  []epinfo.EpisodeInfo
+	selected  map[int]bool
+	onChanged func()
+}
+
+// newEpisodeTree returns an empty tree, onChanged is called whenever the selection changes.
+func newEpisodeTree(onChanged func()) *episodeTree {
+	t := &episodeTree{selected: make(map[int]bool), only: -1, onChanged: onChanged}
+	t.tree = widget.NewTree(t.childUIDs, t.isBranch, t.createNode, t.updateNode)
+	t.sidebar = widget.NewList(func() int { return len(t.groups) + 1 }, t.createSeriesItem, t.updateSeriesItem)
+	t.sidebar.OnSelected = func(id widget.ListItemID) {
+		t.only = id - 1
+		t.changed()
+	}
+	return t
+}
+
+// visible returns the indexes of the series shown in the tree.
+func (t *episodeTree) visible() []int {
+	if t.only >= 0 && t.only < len(t.groups) {
+		return []int{t.only}
+	}
+	shown := []int{}
+	for i := range t.groups {
+		shown = append(shown, i)
+	}
+	return shown
+}
+
+func (t *episodeTree) createSeriesItem() fyne.CanvasObject {
+	url := widget.NewLabel("")
+	url.Wrapping = fyne.TextTruncate
+	return container.NewVBox(widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel(""), url)
+}
+
+// updateSeriesItem shows the name, the episode counts and the source URL of a series.
+// The first item stands for all series.
+func (t *episodeTree) updateSeriesItem(id widget.ListItemID, item fyne.CanvasObject) {
+	labels := item.(*fyne.Container).Objects
+	var episodes []int
+	if id == 0 {
+		labels[0].(*widget.Label).SetText(fmt.Sprintf("All series (%d)", len(t.groups)))
+		labels[2].(*widget.Label).SetText("")
+		for i := range t.episodes {
+			episodes = append(episodes, i)
+		}
+	} else {
+		group := t.groups[id-1]
+		labels[0].(*widget.Label).SetText(group.title)
+		labels[2].(*widget.Label).SetText(group.url)
+		episodes = group.episodes
+	}
+	counts := map[string]int{}
+	for _, e := range episodes {
+		counts[t.episodes[e].Variant()]++
+	}
+	labels[1].(*widget.Label).SetText(fmt.Sprintf("%d episodes, %d standard, %d AD, %d sign",
+		len(episodes), counts["standard"], counts["ad"], counts["sign"]))
+}
+
+// uid returns the tree node id of a series (branch) or an episode.
+func uid(branch bool, i int) widget.TreeNodeID {
+	if branch {
+		return "s" + strconv.Itoa(i)
+	}
+	return "e" + strconv.Itoa(i)
+}
+
+// index returns the series or episode index of a tree node id.
+func index(id widget.TreeNodeID) int {
+	i, _ := strconv.Atoi(id[1:])
+	return i
+}
+
+func (t *episodeTree) childUIDs(id widget.TreeNodeID) []widget.TreeNodeID {
+	ids := []widget.TreeNodeID{}
+	if id == "" {
+		for _, i := range t.visible() {
+			ids = append(ids, uid(true, i))
+		}
+	} else if t.isBranch(id) {
+		for _, i := range t.groups[index(id)].episodes {
+			ids = append(ids, uid(false, i))
+		}
+	}
+	return ids
+}
+
+func (t *episodeTree) isBranch(id widget.TreeNodeID) bool {
+	return id == "" || id[0] == 's'
+}
+
+func (t *episodeTree) createNode(branch bool) fyne.CanvasObject {
+	check := widget.NewCheck("", nil)
+	if branch {
+		return check
+	}
+	return container.NewHBox(check, layout.NewSpacer(), widget.NewLabel(""), widget.NewLabel(""))
+}
+
+func (t *episodeTree) updateNode(id widget.TreeNodeID, branch bool, node fyne.CanvasObject) {
+	i := index(id)
+	if branch {
+		group := t.groups[i]
+		check := node.(*widget.Check)
+		check.Text = fmt.Sprintf("%s (%d)", group.title, len(group.episodes))
+		setCheck(check, t.groupSelected(group), func(on bool) {
+			for _, e := range group.episodes {
+				t.selected[e] = on
+			}
+			t.changed()
+		})
+		return
+	}
+	ep := t.episodes[i]
+	row := node.(*fyne.Container)
+	check := row.Objects[0].(*widget.Check)
+	check.Text = ep.Label
+	setCheck(check, t.selected[i], func(on bool) {
+		t.selected[i] = on
+		t.changed()
+	})
+	row.Objects[2].(*widget.Label).SetText(ep.Series)
+	row.Objects[3].(*widget.Label).SetText(ep.Variant())
+}
+
+// setCheck updates a recycled check box without firing its old handler.
+func setCheck(check *widget.Check, on bool, changed func(bool)) {
+	check.OnChanged = nil
+	check.SetChecked(on)
+	check.OnChanged = changed
+	check.Refresh()
+}
+
+func (t *episodeTree) groupSelected(group episodeGroup) bool {
+	for _, e := range group.episodes {
+		if !t.selected[e] {
+			return false
+		}
+	}
+	return len(group.episodes) > 0
+}
+
+func (t *episodeTree) changed() {
+	t.tree.Refresh()
+	if t.onChanged != nil {
+		t.onChanged()
+	}
+}
+
+// SetShows lists the episodes of the shows passing the filter, all of them selected.
+// Series are sorted by name, prefixed by the show title when there are several shows.
+// Series without episodes are listed too, so the tree shows everything that was found.
+func (t *episodeTree) SetShows(shows []epinfo.ShowEpisodes, f *filter.Filter) {
+	// The lists are built first and set in one step, the tree keeps drawing the old ones meanwhile.
+	var groups []episodeGroup
+	var episodes []epinfo.EpisodeInfo
+	selected := make(map[int]bool)
+	for _, show := range shows {
+		allSeries := f.Apply(show.Series)
+		names := []string{}
+		for name := range allSeries {
+			names = append(names, name)
+		}
+		for name := range show.SeriesURLs {
+			if _, ok := allSeries[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			group := episodeGroup{title: name, url: show.SeriesURLs[name]}
+			if len(shows) > 1 {
+				group.title = show.Title + " / " + name
+			}
+			for _, ep := range allSeries[name] {
+				group.episodes = append(group.episodes, len(episodes))
+				selected[len(episodes)] = true
+				episodes = append(episodes, ep)
+			}
+			groups = append(groups, group)
+		}
+	}
+	t.groups, t.episodes, t.selected, t.only = groups, episodes, selected, -1
+	t.tree.OpenAllBranches()
+	t.sidebar.Select(0)
+	t.sidebar.Refresh()
+	t.changed()
+}
+
+// SelectAll selects or unselects every episode.
+func (t *episodeTree) SelectAll(on bool) {
+	for i := range t.episodes {
+		t.selected[i] = on
+	}
+	t.changed()
+}
+
+// Selected returns the selected episodes of all series in the order they are listed,
+// including those of series the sidebar hides.
+func (t *episodeTree) Selected() []epinfo.EpisodeInfo {
+	selected := []epinfo.EpisodeInfo{}
+	for _, group := range t.groups {
+		for _, e := range group.episodes {
+			if t.selected[e] {
+				selected = append(selected, t.episodes[e])
+			}
+		}
+	}
+	return selected
+}
+
+// Counts returns the number of listed series and episodes.
+func (t *episodeTree) Counts() (int, int) {
+	return len(t.groups), len(t.episodes)
+}
@@ -1,18 +1,13 @@
 package gui
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/url"
-	"os"
-	"os/exec"
-	"runtime"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"fyne.io/fyne"
 	"fyne.io/fyne/app"
@@ -20,19 +15,45 @@ import (
 	"fyne.io/fyne/dialog"
 	"fyne.io/fyne/layout"
 	"fyne.io/fyne/widget"
+	"github.com/gandalf15/iplayerlinks/config"
+	"github.com/gandalf15/iplayerlinks/download"
 	"github.com/gandalf15/iplayerlinks/epinfo"
+	"github.com/gandalf15/iplayerlinks/filter"
+	"github.com/gandalf15/iplayerlinks/ipurl"
 )
 
 // IPlayerLinksGUI holds all widgets and the window of the GUI
 type IPlayerLinksGUI struct {
-	sourceURLEnry, allEpURLEntry *widget.Entry
+	sourceURLEnry                *widget.SelectEntry
+	searchEntry                  *widget.Entry
+	episodes                     *episodeTree
 	tvShow, noSeries, noEpisodes *widget.Label
+	noSelected                   *widget.Label
 	window                       fyne.Window
 	functions                    map[string]func()
 	buttons                      map[string]*widget.Button
 	checks                       map[string]*widget.Check
+	filterEntries                map[string]*widget.Entry
+	quality                      *widget.SelectEntry
 	destDir                      string
-	allEpURL                     []string
+	settings                     settings
+	// showsMu guards shows, the scrape sets them from its goroutine.
+	showsMu sync.Mutex
+	shows   []epinfo.ShowEpisodes
+}
+
+// setShows replaces the found shows.
+func (iplGUI *IPlayerLinksGUI) setShows(shows []epinfo.ShowEpisodes) {
+	iplGUI.showsMu.Lock()
+	defer iplGUI.showsMu.Unlock()
+	iplGUI.shows = shows
+}
+
+// foundShows returns the found shows.
+func (iplGUI *IPlayerLinksGUI) foundShows() []epinfo.ShowEpisodes {
+	iplGUI.showsMu.Lock()
+	defer iplGUI.showsMu.Unlock()
+	return iplGUI.shows
 }
 
 func (iplGUI *IPlayerLinksGUI) addButton(text string, action func()) *widget.Button {
@@ -49,29 +70,48 @@ func (iplGUI *IPlayerLinksGUI) typedKey(ev *fyne.KeyEvent) {
 }
 
 // NewIplayerLinksGUI initialises and returns a pointer to iPlayerLinksGUI
-func NewIplayerLinksGUI(myApp fyne.App) *IPlayerLinksGUI {
+func NewIplayerLinksGUI(myApp fyne.App, cfg config.Config) *IPlayerLinksGUI {
 	iplGUI := &IPlayerLinksGUI{}
 	iplGUI.noSeries = widget.NewLabel("0")
 	iplGUI.noEpisodes = widget.NewLabel("0")
+	iplGUI.noSelected = widget.NewLabel("0")
 	iplGUI.tvShow = widget.NewLabel("")
 	iplGUI.window = myApp.NewWindow("iPlayerLinks")
-	iplGUI.sourceURLEnry = widget.NewEntry()
+	iplGUI.settings = settings{prefs: myApp.Preferences(), cfg: cfg}
+	iplGUI.sourceURLEnry = widget.NewSelectEntry(iplGUI.settings.sourceURLs(iplGUI.settings.history()))
 	iplGUI.sourceURLEnry.SetPlaceHolder("Source iPlayer URL")
-	iplGUI.allEpURLEntry = widget.NewMultiLineEntry()
-	iplGUI.allEpURLEntry.SetPlaceHolder("Links of all found episodes")
+	iplGUI.searchEntry = widget.NewEntry()
+	iplGUI.searchEntry.SetPlaceHolder("Search iPlayer for a show")
+	iplGUI.episodes = newEpisodeTree(func() {
+		iplGUI.noSelected.SetText(strconv.Itoa(len(iplGUI.episodes.Selected())))
+	})
 	iplGUI.buttons = make(map[string]*widget.Button)
 	iplGUI.functions = make(map[string]func())
 	iplGUI.checks = make(map[string]*widget.Check)
+	iplGUI.filterEntries = make(map[string]*widget.Entry)
 	return iplGUI
 }
 
+// selectedLinks returns the links of the selected episodes, one per line.
+func (iplGUI *IPlayerLinksGUI) selectedLinks() string {
+	var links []string
+	for _, epi := range iplGUI.episodes.Selected() {
+		links = append(links, epi.URL)
+	}
+	return strings.Join(links, "\n")
+}
+
 func (iplGUI *IPlayerLinksGUI) saveLinks() {
+	links := iplGUI.selectedLinks()
 	f := func(file fyne.URIWriteCloser, e error) {
+		if e != nil || file == nil {
+			return
+		}
 		defer file.Close()
-		file.Write([]byte(iplGUI.allEpURLEntry.Text))
+		file.Write([]byte(links))
 		iplGUI.window.Content().Refresh()
 	}
-	if len(iplGUI.allEpURLEntry.Text) > 0 {
+	if len(links) > 0 {
 		dialog.ShowFileSave(f, iplGUI.window)
 	} else {
 		d := dialog.NewError(errors.New("Nothing to save"), iplGUI.window)
@@ -79,146 +119,227 @@ func (iplGUI *IPlayerLinksGUI) saveLinks() {
 	}
 }
 
-func (iplGUI *IPlayerLinksGUI) getLinks() {
-	parsedURL, err := url.Parse(iplGUI.sourceURLEnry.Text)
+// useHTTP sets the client of the HTTP settings for all requests.
+func (iplGUI *IPlayerLinksGUI) useHTTP() error {
+	client, err := epinfo.NewClient(iplGUI.settings.http())
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	if !strings.Contains(parsedURL.Host, "bbc.co.uk") || !strings.Contains(parsedURL.Path, "iplayer") {
-		log.Println("Invalid source URL.")
-		d := dialog.NewError(errors.New("Provided source URL is invalid"), iplGUI.window)
+	epinfo.SetHTTPClient(client)
+	return nil
+}
+
+func (iplGUI *IPlayerLinksGUI) getLinks() {
+	sourceURL, err := ipurl.Parse(iplGUI.sourceURLEnry.Text)
+	if err != nil {
+		log.Printf("Invalid source URL: %s", err)
+		d := dialog.NewError(fmt.Errorf("Provided source URL is invalid: %w", err), iplGUI.window)
 		d.Show()
-	} else {
-		allSeries := epinfo.AllEpisodesInfo(iplGUI.sourceURLEnry.Text, iplGUI.checks["audioDescribed"].Checked,
-			iplGUI.checks["signLang"].Checked)
-		if len(allSeries) == 0 {
-			dialog.NewError(errors.New("No additional links found"), iplGUI.window)
-		} else {
-			for _, v := range allSeries {
-				iplGUI.tvShow.SetText(*v[0].TvShow)
-				for _, epi := range v {
-					iplGUI.allEpURL = append(iplGUI.allEpURL, epi.URL)
-				}
+		return
+	}
+	if sourceURL.Kind == ipurl.Search {
+		// Search results are not scraped, the show to scrape is picked from them.
+		iplGUI.searchEntry.SetText(sourceURL.Query)
+		iplGUI.search()
+		return
+	}
+	iplGUI.sourceURLEnry.SetOptions(iplGUI.settings.sourceURLs(iplGUI.settings.addHistory(iplGUI.sourceURLEnry.Text)))
+	iplGUI.setShows(nil)
+	iplGUI.showLinks()
+	epinfo.SetMaxConcurrentFetches(iplGUI.settings.maxFetches())
+	if err := iplGUI.useHTTP(); err != nil {
+		dialog.NewError(err, iplGUI.window).Show()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := widget.NewLabel(progressText(epinfo.Progress{}))
+	d := dialog.NewCustom("Getting Links", "Cancel", container.NewVBox(widget.NewProgressBarInfinite(), progress),
+		iplGUI.window)
+	d.SetOnClosed(cancel)
+	iplGUI.buttons["getLinks"].Disable()
+	d.Show()
+	onProgress := func(p epinfo.Progress) { progress.SetText(progressText(p)) }
+	go func() {
+		shows, err := iplGUI.scrape(ctx, sourceURL, onProgress)
+		iplGUI.buttons["getLinks"].Enable()
+		if ctx.Err() != nil {
+			log.Println("Getting links cancelled")
+			return
+		}
+		d.Hide()
+		if err != nil {
+			log.Println(err)
+			dialog.NewError(err, iplGUI.window).Show()
+		}
+		if len(shows) == 0 {
+			if err == nil {
+				dialog.NewError(errors.New("No additional links found"), iplGUI.window).Show()
 			}
-			iplGUI.allEpURLEntry.SetText(strings.Join(iplGUI.allEpURL, "\n"))
-			iplGUI.noSeries.SetText(strconv.FormatInt(int64(len(allSeries)), 10))
-			iplGUI.noEpisodes.SetText(strconv.FormatInt(int64(len(iplGUI.allEpURL)), 10))
+			return
+		}
+		iplGUI.setShows(shows)
+		iplGUI.showLinks()
+	}()
+}
+
+// progressText describes the progress of a scrape.
+func progressText(p epinfo.Progress) string {
+	return fmt.Sprintf("Pages fetched: %d\nSeries discovered: %d\nEpisodes found: %d",
+		p.PagesFetched, p.SeriesFound, p.EpisodesFound)
+}
 
+// scrape gets the episodes of the source URL, it runs off the UI thread and stops once ctx is cancelled.
+func (iplGUI *IPlayerLinksGUI) scrape(ctx context.Context, sourceURL *ipurl.URL,
+	onProgress func(epinfo.Progress)) ([]epinfo.ShowEpisodes, error) {
+	audioDescribed, signLang := iplGUI.checks["audioDescribed"].Checked, iplGUI.checks["signLang"].Checked
+	if sourceURL.Kind == ipurl.Listing {
+		shows, err := epinfo.CrawlListingContext(ctx, sourceURL.String(), audioDescribed, signLang, onProgress)
+		for _, show := range shows {
+			if show.Err != nil {
+				log.Printf("%s: %s", show.Title, show.Err)
+			}
 		}
+		return shows, err
+	}
+	var allSeries map[string][]epinfo.EpisodeInfo
+	var diag epinfo.Diagnostics
+	var err error
+	if sourceURL.Kind == ipurl.Episode && iplGUI.checks["singleEpisode"].Checked {
+		var episodes []epinfo.EpisodeInfo
+		episodes, err = epinfo.SingleEpisodeInfoContext(ctx, sourceURL.String(), audioDescribed, signLang, onProgress)
+		allSeries = map[string][]epinfo.EpisodeInfo{}
+		diag.SeriesURLs = map[string]string{}
+		if len(episodes) > 0 {
+			allSeries[episodes[0].Series] = episodes
+			diag.SeriesURLs[episodes[0].Series] = sourceURL.String()
+		}
+	} else {
+		allSeries, diag, err = epinfo.AllEpisodesInfoContext(ctx, sourceURL.String(), audioDescribed, signLang,
+			onProgress)
+	}
+	if len(allSeries) == 0 {
+		return nil, err
+	}
+	return []epinfo.ShowEpisodes{{Series: allSeries, SeriesURLs: diag.SeriesURLs}}, err
+}
 
+// filterSpec returns the filter typed in the filter panel.
+func (iplGUI *IPlayerLinksGUI) filterSpec() filter.Spec {
+	return filter.Spec{
+		Series:     iplGUI.filterEntries["series"].Text,
+		Label:      iplGUI.filterEntries["label"].Text,
+		LabelRegex: iplGUI.filterEntries["labelRegex"].Text,
+		Episodes:   iplGUI.filterEntries["episodes"].Text,
+		Variants:   iplGUI.filterEntries["variants"].Text,
+		From:       iplGUI.filterEntries["from"].Text,
+		To:         iplGUI.filterEntries["to"].Text,
 	}
 }
 
-func (iplGUI *IPlayerLinksGUI) downloadAllEpisodes() {
-	f := func(uri fyne.ListableURI, err error) {
-		if err != nil {
-			log.Fatalf("Error while opening destination folder %s", err.Error())
-		}
-		destDir := uri.String()
-		iplGUI.destDir = strings.Replace(destDir, "file://", "", 1)
-		sub := ""
-		if iplGUI.checks["subtitles"].Checked {
-			sub = "--all-subs"
+// showLinks lists the found episodes that pass the filter, all of them selected.
+func (iplGUI *IPlayerLinksGUI) showLinks() {
+	f, err := iplGUI.filterSpec().Compile()
+	if err != nil {
+		dialog.NewError(err, iplGUI.window).Show()
+		return
+	}
+	shows := iplGUI.foundShows()
+	iplGUI.episodes.SetShows(shows, f)
+	iplGUI.tvShow.SetText("")
+	if len(shows) > 1 {
+		iplGUI.tvShow.SetText(fmt.Sprintf("%d shows", len(shows)))
+	} else if episodes := iplGUI.episodes.episodes; len(episodes) > 0 {
+		iplGUI.tvShow.SetText(*episodes[0].TvShow)
+	}
+	noSeries, noEpisodes := iplGUI.episodes.Counts()
+	iplGUI.noSeries.SetText(strconv.Itoa(noSeries))
+	iplGUI.noEpisodes.SetText(strconv.Itoa(noEpisodes))
+}
+
+// filterPanel returns the form to filter the found episodes.
+func (iplGUI *IPlayerLinksGUI) filterPanel() fyne.CanvasObject {
+	form := widget.NewForm()
+	fields := []struct{ key, label, placeHolder string }{
+		{"series", "Series", "Text in the series name"},
+		{"label", "Label", "Text in the episode label"},
+		{"labelRegex", "Label Regex", "Regular expression matching the label"},
+		{"episodes", "Episodes", "S2E3-S2E8,S3,E1-E4"},
+		{"variants", "Variants", "standard,ad,sign"},
+		{"from", "Aired From", filter.DateLayout},
+		{"to", "Aired To", filter.DateLayout},
+	}
+	for _, field := range fields {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder(field.placeHolder)
+		iplGUI.filterEntries[field.key] = entry
+		form.Append(field.label, entry)
+	}
+	iplGUI.functions["applyFilter"] = func() { iplGUI.showLinks() }
+	iplGUI.buttons["applyFilter"] = widget.NewButton("Apply Filter", iplGUI.functions["applyFilter"])
+	return widget.NewAccordion(widget.NewAccordionItem("Filter",
+		container.NewVBox(form, iplGUI.buttons["applyFilter"])))
+}
+
+// search looks for the text of the search box off the UI thread, the search can be cancelled.
+func (iplGUI *IPlayerLinksGUI) search() {
+	query := iplGUI.searchEntry.Text
+	ctx, cancel := context.WithCancel(context.Background())
+	d := dialog.NewCustom("Searching", "Cancel", widget.NewProgressBarInfinite(), iplGUI.window)
+	d.SetOnClosed(cancel)
+	iplGUI.buttons["search"].Disable()
+	d.Show()
+	go func() {
+		shows, err := epinfo.SearchContext(ctx, query)
+		iplGUI.buttons["search"].Enable()
+		if ctx.Err() != nil {
+			log.Println("Search cancelled")
+			return
 		}
-		ydl := exec.Command("youtube-dl", "-f", "best", sub, "-o",
-			iplGUI.destDir+"/%(title)s-%(release_date)s.%(ext)s", "-a", "-")
-		stdin, err := ydl.StdinPipe()
+		d.Hide()
 		if err != nil {
-			log.Fatalf("Error obtaining stdin: %s", err)
+			log.Println(err)
+			dialog.NewError(err, iplGUI.window).Show()
+			return
 		}
-		stdout, err := ydl.StdoutPipe()
-		if err != nil {
-			log.Fatalf("Error obtaining stdout: %s", err)
+		if len(shows) == 0 {
+			dialog.NewError(fmt.Errorf("Nothing found for %q", query), iplGUI.window).Show()
+			return
 		}
+		iplGUI.showSearchResults(shows)
+	}()
+}
 
-		scanner := bufio.NewScanner(stdout)
-		scanner.Split(bufio.ScanRunes)
-		go func() {
-			f := func() {
-				err := errors.New("Try to interupt if not Windows OS")
-				if ydl.ProcessState != nil && !ydl.ProcessState.Exited() {
-					if runtime.GOOS != "windows" {
-						err = ydl.Process.Signal(os.Interrupt)
-						time.Sleep(time.Second * 3)
-					}
-					if err != nil {
-						log.Printf("Failed to interrupt youtube-dl process. Error: %s\nKilling the process now...", err)
-						err2 := ydl.Process.Kill()
-						if err2 != nil {
-							d := dialog.NewError(errors.New("Failed to kill youtube-dl process"), iplGUI.window)
-							d.Show()
-							log.Fatalf("Failed to kill youtube-dl process: %s", err.Error())
-						}
-					}
-				}
-			}
-			entry := widget.NewMultiLineEntry()
-			entry.SetReadOnly(true)
-			scrollCont := container.NewScroll(entry)
-			scrollCont.SetMinSize(fyne.NewSize(600, 400))
-			downloadingCont := container.NewVBox(widget.NewProgressBarInfinite(), scrollCont)
-			d := dialog.NewCustom("Downloading", "Cancel", downloadingCont, iplGUI.window)
-			d.SetOnClosed(f)
-			d.Show()
-			textOut := []string{}
-			for scanner.Scan() {
-				newText := scanner.Text()
-				lastNewLine := 0
-				if newText == "\r" {
-					textOut = textOut[:lastNewLine]
-				} else if newText == "\n" {
-					lastNewLine = len(textOut)
-				}
-				textOut = append(textOut, newText)
-				entry.SetText(strings.Join(textOut, ""))
-				scrollCont.ScrollToBottom()
-			}
-			d.Hide()
-		}()
-
-		go func() {
-			defer stdin.Close()
-			io.WriteString(stdin, iplGUI.allEpURLEntry.Text)
-		}()
-
-		if err := ydl.Start(); nil != err {
-			log.Printf("Install youtube-dl first. Failed to start: %s, %s", ydl.Path, err.Error())
-			errorDialog := dialog.NewError(fmt.Errorf("Install youtube-dl first. Failed to start: %s, %s",
-				ydl.Path, err.Error()), iplGUI.window)
-			errorDialog.Show()
-		}
-		go func() {
-			err := ydl.Wait()
-			if err != nil {
-				log.Printf("Error running the command: '%s' \nError: '%s'", ydl.String(), err)
-				errorDialog := dialog.NewError(fmt.Errorf("Error running the command: '%s' \nError: '%s'",
-					ydl.String(), err), iplGUI.window)
-				errorDialog.Show()
-			} else {
-				successDialog := dialog.NewInformation("Finished", "Success", iplGUI.window)
-				successDialog.Show()
-			}
-		}()
-	}
-	if len(iplGUI.allEpURLEntry.Text) > 0 {
-		d := dialog.NewFolderOpen(f, iplGUI.window)
-		d.Show()
-	} else {
-		d := dialog.NewError(errors.New("Nothing to download"), iplGUI.window)
-		d.Show()
+// showSearchResults lists the shows found by a search and gets the links of the one picked.
+func (iplGUI *IPlayerLinksGUI) showSearchResults(shows []epinfo.Show) {
+	var d dialog.Dialog
+	list := widget.NewList(func() int { return len(shows) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) { item.(*widget.Label).SetText(shows[id].Title) })
+	list.OnSelected = func(id widget.ListItemID) {
+		iplGUI.sourceURLEnry.SetText(shows[id].URL)
+		d.Hide()
+		iplGUI.getLinks()
 	}
+	scrollCont := container.NewScroll(list)
+	scrollCont.SetMinSize(fyne.NewSize(600, 400))
+	d = dialog.NewCustom("Search Results", "Cancel", scrollCont, iplGUI.window)
+	d.Show()
 }
 
-// Gui creates and shows GUI for iPlayerLinks
-func Gui() {
-	myApp := app.New()
+// Gui creates and shows GUI for iPlayerLinks.
+// The settings never changed in the GUI default to cfg.
+func Gui(cfg config.Config) {
+	myApp := app.NewWithID(appID)
 	myApp.SetIcon(resourceIconPng)
-	iplGUI := NewIplayerLinksGUI(myApp)
+	iplGUI := NewIplayerLinksGUI(myApp, cfg)
+	if err := iplGUI.useHTTP(); err != nil {
+		log.Printf("Invalid HTTP settings: %s", err)
+	}
 	statusBar := widget.NewHBox(widget.NewLabel("No. Of Series:"), iplGUI.noSeries,
 		layout.NewSpacer(), iplGUI.tvShow, layout.NewSpacer(),
-		widget.NewLabel("No. Of All Ep:"), iplGUI.noEpisodes)
+		widget.NewLabel("No. Of All Ep:"), iplGUI.noEpisodes, widget.NewLabel("Selected:"), iplGUI.noSelected)
 
-	iplGUI.buttons["saveLinks"] = widget.NewButton("Save Links To File", func() {})
+	iplGUI.buttons["saveLinks"] = widget.NewButton("Save Selected Links To File", func() {})
 	iplGUI.functions["saveLinks"] = func() { iplGUI.saveLinks() }
 	iplGUI.buttons["saveLinks"].OnTapped = iplGUI.functions["saveLinks"]
 
@@ -226,19 +347,46 @@ func Gui() {
 	iplGUI.functions["getLinks"] = func() { iplGUI.getLinks() }
 	iplGUI.buttons["getLinks"].OnTapped = iplGUI.functions["getLinks"]
 
+	iplGUI.functions["search"] = func() { iplGUI.search() }
+	iplGUI.buttons["search"] = widget.NewButton("Search", iplGUI.functions["search"])
+
 	iplGUI.functions["downloadAll"] = func() { iplGUI.downloadAllEpisodes() }
-	iplGUI.buttons["downloadAll"] = widget.NewButton("Download All Episodes", iplGUI.functions["downloadAll"])
+	iplGUI.buttons["downloadAll"] = widget.NewButton("Download Selected Episodes", iplGUI.functions["downloadAll"])
+	iplGUI.functions["listFormats"] = func() { iplGUI.listFormats() }
+	iplGUI.buttons["listFormats"] = widget.NewButton("List Formats", iplGUI.functions["listFormats"])
+	iplGUI.quality = widget.NewSelectEntry(download.Qualities)
+	iplGUI.quality.SetText(iplGUI.settings.quality())
 
 	iplGUI.checks["audioDescribed"] = widget.NewCheck("Audio Described Links", func(bool) {})
 	iplGUI.checks["signLang"] = widget.NewCheck("Sign Language Links", func(bool) {})
+	iplGUI.checks["singleEpisode"] = widget.NewCheck("Only This Episode", func(bool) {})
 	iplGUI.checks["subtitles"] = widget.NewCheck("Download Subtitles", func(bool) {})
+	iplGUI.settings.bindCheck(iplGUI.checks["audioDescribed"], prefAudioDescribed, cfg.Variant("ad"))
+	iplGUI.settings.bindCheck(iplGUI.checks["signLang"], prefSignLang, cfg.Variant("sign"))
+	iplGUI.settings.bindCheck(iplGUI.checks["subtitles"], prefSubtitles, false)
+
+	iplGUI.functions["settings"] = func() { iplGUI.showSettings() }
+	iplGUI.buttons["settings"] = widget.NewButton("Settings", iplGUI.functions["settings"])
 
-	subtitleCont := container.NewHBox(layout.NewSpacer(), iplGUI.checks["subtitles"], layout.NewSpacer())
+	subtitleCont := container.NewHBox(layout.NewSpacer(), iplGUI.checks["subtitles"], layout.NewSpacer(),
+		widget.NewLabel("Quality:"),
+		fyne.NewContainerWithLayout(layout.NewGridWrapLayout(fyne.NewSize(160, iplGUI.quality.MinSize().Height)), iplGUI.quality),
+		iplGUI.buttons["listFormats"], layout.NewSpacer())
 	bottomContainer := container.NewVBox(iplGUI.buttons["saveLinks"], subtitleCont, iplGUI.buttons["downloadAll"], statusBar)
-	allSeriesContainer := container.NewScroll(iplGUI.allEpURLEntry)
-	checksContainer := container.NewHBox(iplGUI.checks["audioDescribed"], layout.NewSpacer(), iplGUI.checks["signLang"])
-	topContainer := container.NewVBox(container.NewScroll(iplGUI.sourceURLEnry), checksContainer,
-		iplGUI.buttons["getLinks"])
+	iplGUI.functions["selectAll"] = func() { iplGUI.episodes.SelectAll(true) }
+	iplGUI.buttons["selectAll"] = widget.NewButton("Select All", iplGUI.functions["selectAll"])
+	iplGUI.functions["selectNone"] = func() { iplGUI.episodes.SelectAll(false) }
+	iplGUI.buttons["selectNone"] = widget.NewButton("Select None", iplGUI.functions["selectNone"])
+	selectCont := container.NewHBox(layout.NewSpacer(), iplGUI.buttons["selectAll"], iplGUI.buttons["selectNone"])
+	episodesContainer := container.NewBorder(selectCont, nil, nil, nil, iplGUI.episodes.tree)
+	allSeriesContainer := container.NewHSplit(iplGUI.episodes.sidebar, episodesContainer)
+	allSeriesContainer.SetOffset(0.3)
+	checksContainer := container.NewHBox(iplGUI.checks["audioDescribed"], layout.NewSpacer(),
+		iplGUI.checks["singleEpisode"], layout.NewSpacer(), iplGUI.checks["signLang"])
+	searchContainer := container.NewBorder(nil, nil, nil,
+		container.NewHBox(iplGUI.buttons["search"], iplGUI.buttons["settings"]), iplGUI.searchEntry)
+	topContainer := container.NewVBox(searchContainer, iplGUI.sourceURLEnry, checksContainer,
+		iplGUI.buttons["getLinks"], iplGUI.filterPanel())
 	content := container.NewBorder(topContainer, bottomContainer, nil, nil, allSeriesContainer)
 	iplGUI.window.Resize(fyne.NewSize(800, 600))
 	iplGUI.window.CenterOnScreen()
@@ -2,20 +2,62 @@ package main
 
 import (
 	"flag"
+	"log"
+	"os"
 
 	"github.com/gandalf15/iplayerlinks/cli"
+	"github.com/gandalf15/iplayerlinks/config"
+	"github.com/gandalf15/iplayerlinks/epinfo"
 	"github.com/gandalf15/iplayerlinks/gui"
 )
 
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	epinfo.SetSavedPagesDir(cfg.CacheDir)
+	if len(os.Args) > 1 && os.Args[1] == "search" {
+		cli.Search(cfg, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "download" {
+		cli.Download(cfg, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "formats" {
+		cli.Formats(cfg, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "subscriptions" {
+		cli.Subscriptions(cfg, os.Args[2:])
+		return
+	}
 	urlPtr := flag.String("url", "", "-url=[iPlayer URL with episodes]")
-	audioDescribedPtr := flag.Bool("audioDescribed", false, "-audioDescribed=[bool]")
-	signLangPtr := flag.Bool("signLang", false, "-signLang=[bool]")
+	audioDescribedPtr := flag.Bool("audioDescribed", cfg.Variant("ad"), "-audioDescribed=[bool]")
+	signLangPtr := flag.Bool("signLang", cfg.Variant("sign"), "-signLang=[bool]")
+	verbosePtr := flag.Bool("verbose", false, "-verbose=[bool]")
+	singleEpisodePtr := flag.Bool("singleEpisode", false, "-singleEpisode=[bool] only the episode of an episode URL")
+	streamPtr := flag.Bool("stream", false, "-stream=[bool] print the links as soon as they are found")
+	maxFetchesPtr := flag.Int("maxFetches", cfg.RateLimit.MaxFetches, "-maxFetches=[int] pages fetched at the same time, 0 for no limit")
+	filterSpec := cli.FilterFlags(flag.CommandLine)
+	httpOpts := cli.HTTPFlags(flag.CommandLine, cfg.HTTP)
 	flag.Parse()
 	if *urlPtr == "" {
-		gui.Gui()
+		cfg.HTTP = *httpOpts
+		gui.Gui(cfg)
 	} else {
-		cli.Cli(urlPtr, audioDescribedPtr, signLangPtr)
+		cli.Cli(cli.Options{
+			URL:            *urlPtr,
+			AudioDescribed: *audioDescribedPtr,
+			SignLang:       *signLangPtr,
+			Verbose:        *verbosePtr,
+			SingleEpisode:  *singleEpisodePtr,
+			Stream:         *streamPtr,
+			MaxFetches:     *maxFetchesPtr,
+			Filter:         *filterSpec,
+			HTTP:           *httpOpts,
+		})
 	}
 
 }
@@ -0,0 +1,230 @@
+// Package ipurl classifies, normalises and resolves BBC iPlayer URLs.
+package ipurl
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kind tells what an iPlayer URL points to.
+type Kind int
+
+// Kinds of iPlayer URLs
+const (
+	Unknown  Kind = iota
+	Brand         // /programmes/<pid>
+	Episodes      // /iplayer/episodes/<pid>/<slug>
+	Series        // /iplayer/episodes/<pid>/<slug>?seriesId=<pid>
+	Episode       // /iplayer/episode/<pid>[/ad|/sign]/<slug>
+	Listing       // /iplayer/categories/..., /iplayer/a-z/... or /iplayer/tv/<channel>/a-z
+	Search        // /iplayer/search?q=...
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Brand:
+		return "brand"
+	case Episodes:
+		return "episodes"
+	case Series:
+		return "series"
+	case Episode:
+		return "episode"
+	case Listing:
+		return "listing"
+	case Search:
+		return "search"
+	}
+	return "unknown"
+}
+
+// Host is the canonical host of iPlayer URLs.
+const Host = "www.bbc.co.uk"
+
+// Errors returned by Parse
+var (
+	ErrHost      = errors.New("not a bbc.co.uk URL")
+	ErrNotPlayer = errors.New("not an iPlayer URL")
+)
+
+// pidRe matches a BBC programme identifier.
+var pidRe = regexp.MustCompile(`^(?:[pbml][0-9a-z]{7}|w[0-9a-z]{7,14})$`)
+
+// IsPID reports whether s looks like a BBC programme identifier.
+func IsPID(s string) bool {
+	return pidRe.MatchString(s)
+}
+
+// URL is a parsed iPlayer URL.
+// Variant is "ad" or "sign" for audio described and sign language episodes.
+// Page is 0 when the URL has no page number.
+// Path and Sort are only set for listings and searches, which have no programme id, Query only for searches.
+type URL struct {
+	Kind     Kind
+	PID      string
+	SeriesID string
+	Variant  string
+	Slug     string
+	Page     int
+	Path     string
+	Sort     string
+	Query    string
+}
+
+// SearchURL returns the iPlayer search results page for query.
+func SearchURL(query string) *URL {
+	return &URL{Kind: Search, Path: "/iplayer/search", Query: strings.TrimSpace(query)}
+}
+
+// ValidHost reports whether host is bbc.co.uk or one of its subdomains.
+// Lookalikes such as notbbc.co.uk or bbc.co.uk.example.com are rejected.
+func ValidHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host == "bbc.co.uk" || strings.HasSuffix(host, ".bbc.co.uk")
+}
+
+// Parse classifies raw. Query parameters other than seriesId and page and the fragment are dropped.
+// A URL without a scheme is assumed to be https.
+func Parse(raw string) (*URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || !ValidHost(u.Hostname()) {
+		return nil, fmt.Errorf("%w: %s", ErrHost, raw)
+	}
+	parts := strings.Split(strings.Trim(path.Clean(u.Path), "/"), "/")
+	res := &URL{}
+	switch {
+	case len(parts) >= 3 && parts[0] == "iplayer" && parts[1] == "episodes":
+		res.Kind = Episodes
+		res.PID = parts[2]
+		if len(parts) > 3 {
+			res.Slug = parts[3]
+		}
+	case len(parts) >= 3 && parts[0] == "iplayer" && parts[1] == "episode":
+		res.Kind = Episode
+		res.PID = parts[2]
+		rest := parts[3:]
+		if len(rest) > 0 && (rest[0] == "ad" || rest[0] == "sign") {
+			res.Variant = rest[0]
+			rest = rest[1:]
+		}
+		if len(rest) > 0 {
+			res.Slug = rest[0]
+		}
+	case len(parts) == 2 && parts[0] == "programmes":
+		res.Kind = Brand
+		res.PID = parts[1]
+	case len(parts) >= 2 && parts[0] == "iplayer" && (parts[1] == "categories" || parts[1] == "a-z"),
+		len(parts) == 4 && parts[0] == "iplayer" && parts[1] == "tv" && parts[3] == "a-z":
+		res.Kind = Listing
+		res.Path = "/" + strings.Join(parts, "/")
+		res.Sort = u.Query().Get("sort")
+	case len(parts) == 2 && parts[0] == "iplayer" && parts[1] == "search":
+		res.Kind = Search
+		res.Path = "/" + strings.Join(parts, "/")
+		res.Sort = u.Query().Get("sort")
+		res.Query = u.Query().Get("q")
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNotPlayer, raw)
+	}
+	if res.Kind != Listing && res.Kind != Search && !IsPID(res.PID) {
+		return nil, fmt.Errorf("%w: invalid programme id %q in %s", ErrNotPlayer, res.PID, raw)
+	}
+	q := u.Query()
+	if sid := q.Get("seriesId"); sid != "" && res.Kind != Episode {
+		if !IsPID(sid) {
+			return nil, fmt.Errorf("%w: invalid series id %q in %s", ErrNotPlayer, sid, raw)
+		}
+		res.SeriesID = sid
+		if res.Kind == Episodes {
+			res.Kind = Series
+		}
+	}
+	if page, err := strconv.Atoi(q.Get("page")); err == nil && page > 0 {
+		res.Page = page
+	}
+	return res, nil
+}
+
+// String returns the canonical form of the URL.
+func (u *URL) String() string {
+	var p string
+	switch u.Kind {
+	case Episode:
+		p = path.Join("/iplayer/episode", u.PID, u.Variant, u.Slug)
+	case Brand:
+		p = path.Join("/programmes", u.PID)
+	case Listing, Search:
+		p = u.Path
+	default:
+		p = path.Join("/iplayer/episodes", u.PID, u.Slug)
+	}
+	q := url.Values{}
+	if u.Sort != "" {
+		q.Set("sort", u.Sort)
+	}
+	if u.Query != "" {
+		q.Set("q", u.Query)
+	}
+	if u.SeriesID != "" {
+		q.Set("seriesId", u.SeriesID)
+	}
+	if u.Page > 0 {
+		q.Set("page", strconv.Itoa(u.Page))
+	}
+	res := url.URL{Scheme: "https", Host: Host, Path: p, RawQuery: q.Encode()}
+	return res.String()
+}
+
+// ListURL returns the canonical iPlayer episodes list of the URL without series and page.
+// Episode URLs are returned unchanged as they do not name their list,
+// listings and searches are returned without their page.
+func (u *URL) ListURL() string {
+	list := *u
+	if list.Kind == Series || list.Kind == Brand {
+		list.Kind = Episodes
+	}
+	list.SeriesID = ""
+	list.Page = 0
+	return list.String()
+}
+
+// Normalise returns the canonical form of raw.
+func Normalise(raw string) (string, error) {
+	u, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// Resolve makes href absolute against base, the URL of the page it was found on.
+// The fragment is dropped. The result is empty unless it is an http or https URL on bbc.co.uk,
+// so links leaving the site are ignored by the callers.
+func Resolve(base string, href string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		b = &url.URL{}
+	}
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	res := b.ResolveReference(ref)
+	if (res.Scheme != "http" && res.Scheme != "https") || !ValidHost(res.Hostname()) {
+		return ""
+	}
+	res.Fragment = ""
+	return res.String()
+}
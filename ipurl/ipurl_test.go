@@ -0,0 +1,159 @@
+package ipurl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    URL
+		wantErr error
+	}{
+		{
+			raw:  "https://www.bbc.co.uk/iplayer/episodes/b006q2x0/doctor-who",
+			want: URL{Kind: Episodes, PID: "b006q2x0", Slug: "doctor-who"},
+		},
+		{
+			raw:  "www.bbc.co.uk/iplayer/episodes/b006q2x0/doctor-who?seriesId=p0fq8ftc&page=2#top",
+			want: URL{Kind: Series, PID: "b006q2x0", Slug: "doctor-who", SeriesID: "p0fq8ftc", Page: 2},
+		},
+		{
+			raw:  "https://www.bbc.co.uk/iplayer/episode/m001zh3r/ad/doctor-who-series-14-1-space-babies",
+			want: URL{Kind: Episode, PID: "m001zh3r", Variant: "ad", Slug: "doctor-who-series-14-1-space-babies"},
+		},
+		{
+			// An episode does not belong to the series of its query.
+			raw:  "https://www.bbc.co.uk/iplayer/episode/m001zh3r?seriesId=p0fq8ftc",
+			want: URL{Kind: Episode, PID: "m001zh3r"},
+		},
+		{
+			raw:  "http://bbc.co.uk/programmes/b006q2x0",
+			want: URL{Kind: Brand, PID: "b006q2x0"},
+		},
+		{
+			raw:  "https://www.bbc.co.uk/iplayer/categories/drama-crime/featured?sort=atoz&page=3",
+			want: URL{Kind: Listing, Path: "/iplayer/categories/drama-crime/featured", Sort: "atoz", Page: 3},
+		},
+		{
+			raw:  "https://www.bbc.co.uk/iplayer/tv/bbcone/a-z",
+			want: URL{Kind: Listing, Path: "/iplayer/tv/bbcone/a-z"},
+		},
+		{
+			raw:  "https://www.bbc.co.uk/iplayer/search?q=doctor+who&page=2",
+			want: URL{Kind: Search, Path: "/iplayer/search", Query: "doctor who", Page: 2},
+		},
+		{raw: "https://notbbc.co.uk/iplayer/episodes/b006q2x0", wantErr: ErrHost},
+		{raw: "https://www.bbc.co.uk.example.com/iplayer/episodes/b006q2x0", wantErr: ErrHost},
+		{raw: "ftp://www.bbc.co.uk/iplayer/episodes/b006q2x0", wantErr: ErrHost},
+		{raw: "https://www.bbc.co.uk/news", wantErr: ErrNotPlayer},
+		{raw: "https://www.bbc.co.uk/iplayer/episodes/nope", wantErr: ErrNotPlayer},
+		{raw: "https://www.bbc.co.uk/iplayer/episodes/b006q2x0?seriesId=nope", wantErr: ErrNotPlayer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := Parse(tt.raw)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		raw, want, list string
+	}{
+		{
+			raw:  "bbc.co.uk/iplayer/episodes/b006q2x0/doctor-who?seriesId=p0fq8ftc&page=2&foo=bar",
+			want: "https://www.bbc.co.uk/iplayer/episodes/b006q2x0/doctor-who?page=2&seriesId=p0fq8ftc",
+			list: "https://www.bbc.co.uk/iplayer/episodes/b006q2x0/doctor-who",
+		},
+		{
+			raw:  "https://www.bbc.co.uk/programmes/b006q2x0",
+			want: "https://www.bbc.co.uk/programmes/b006q2x0",
+			list: "https://www.bbc.co.uk/iplayer/episodes/b006q2x0",
+		},
+		{
+			raw:  "https://www.bbc.co.uk/iplayer/episode/m001zh3r/sign/space-babies#x",
+			want: "https://www.bbc.co.uk/iplayer/episode/m001zh3r/sign/space-babies",
+			list: "https://www.bbc.co.uk/iplayer/episode/m001zh3r/sign/space-babies",
+		},
+		{
+			raw:  "https://www.bbc.co.uk/iplayer/search?page=2&q=doctor%20who",
+			want: "https://www.bbc.co.uk/iplayer/search?page=2&q=doctor+who",
+			list: "https://www.bbc.co.uk/iplayer/search?q=doctor+who",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			u, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if got := u.ListURL(); got != tt.list {
+				t.Errorf("ListURL() = %q, want %q", got, tt.list)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	const base = "https://www.bbc.co.uk/iplayer/episodes/b006q2x0/doctor-who?page=2"
+	tests := []struct {
+		href, want string
+	}{
+		{"/iplayer/episode/m001zh3r/space-babies", "https://www.bbc.co.uk/iplayer/episode/m001zh3r/space-babies"},
+		{"?seriesId=p0fq8ftc", "https://www.bbc.co.uk/iplayer/episodes/b006q2x0/doctor-who?seriesId=p0fq8ftc"},
+		{" /iplayer/episode/m001zh3r#play ", "https://www.bbc.co.uk/iplayer/episode/m001zh3r"},
+		{"//www.bbc.co.uk/iplayer/episode/m001zh3r", "https://www.bbc.co.uk/iplayer/episode/m001zh3r"},
+		{"https://ichef.bbci.co.uk/images/a.jpg", ""},
+		{"https://www.bbc.co.uk.example.com/iplayer/episode/m001zh3r", ""},
+		{"//notbbc.co.uk/iplayer/episode/m001zh3r", ""},
+		{"javascript:alert(1)", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.href, func(t *testing.T) {
+			if got := Resolve(base, tt.href); got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.href, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidHost(t *testing.T) {
+	tests := map[string]bool{
+		"bbc.co.uk":             true,
+		"www.bbc.co.uk":         true,
+		"WWW.BBC.CO.UK.":        true,
+		"notbbc.co.uk":          false,
+		"bbc.co.uk.example.com": false,
+		"ichef.bbci.co.uk":      false,
+		"":                      false,
+	}
+	for host, want := range tests {
+		if got := ValidHost(host); got != want {
+			t.Errorf("ValidHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestSearchURL(t *testing.T) {
+	u := SearchURL("  doctor who ")
+	if u.Kind != Search || u.String() != "https://www.bbc.co.uk/iplayer/search?q=doctor+who" {
+		t.Errorf("got %s %s", u.Kind, u)
+	}
+}
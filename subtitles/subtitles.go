@@ -0,0 +1,181 @@
+// Package subtitles converts the TTML subtitles of iPlayer to SRT and WebVTT.
+package subtitles
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of subtitle files
+const (
+	TTML   = "ttml"
+	SRT    = "srt"
+	WebVTT = "vtt"
+)
+
+// Formats lists the subtitle formats in the order they are offered.
+var Formats = []string{SRT, WebVTT, TTML}
+
+// Cue is a subtitle shown from Start to End, lines of Text are separated by "\n".
+type Cue struct {
+	Start, End time.Duration
+	Text       string
+}
+
+// clockRe matches clock times like 00:01:02.500 or 00:01:02:12 with frames.
+var clockRe = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2})(?:([.:])(\d+))?$`)
+
+// offsetRe matches offset times like 62.5s, 500ms or 900000t.
+var offsetRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)(h|m|s|ms|f|t)$`)
+
+// timing holds the attributes of the tt element that times depend on.
+type timing struct {
+	frameRate, tickRate float64
+}
+
+func (t timing) parse(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if m := clockRe.FindStringSubmatch(value); m != nil {
+		h, _ := strconv.Atoi(m[1])
+		min, _ := strconv.Atoi(m[2])
+		sec, _ := strconv.Atoi(m[3])
+		d := time.Duration(h)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+		switch m[4] {
+		case ".":
+			frac, _ := strconv.ParseFloat("0."+m[5], 64)
+			d += time.Duration(frac * float64(time.Second))
+		case ":":
+			frames, _ := strconv.Atoi(m[5])
+			d += time.Duration(float64(frames) / t.frameRate * float64(time.Second))
+		}
+		return d, nil
+	}
+	if m := offsetRe.FindStringSubmatch(value); m != nil {
+		n, _ := strconv.ParseFloat(m[1], 64)
+		unit := map[string]float64{"h": 3600, "m": 60, "s": 1, "ms": 0.001, "f": 1 / t.frameRate, "t": 1 / t.tickRate}[m[2]]
+		return time.Duration(n * unit * float64(time.Second)), nil
+	}
+	return 0, fmt.Errorf("invalid TTML time %q", value)
+}
+
+// ParseTTML reads the timed paragraphs of a TTML document.
+// Line breaks are kept, styling is dropped.
+func ParseTTML(r io.Reader) ([]Cue, error) {
+	dec := xml.NewDecoder(r)
+	t := timing{frameRate: 30, tickRate: 1}
+	cues := []Cue{}
+	var cue *Cue
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "tt":
+				for _, attr := range el.Attr {
+					if n, err := strconv.ParseFloat(attr.Value, 64); err == nil && n > 0 {
+						switch attr.Name.Local {
+						case "frameRate":
+							t.frameRate = n
+						case "tickRate":
+							t.tickRate = n
+						}
+					}
+				}
+			case "p":
+				cue = &Cue{}
+				text.Reset()
+				for _, attr := range el.Attr {
+					var err error
+					switch attr.Name.Local {
+					case "begin":
+						cue.Start, err = t.parse(attr.Value)
+					case "end":
+						cue.End, err = t.parse(attr.Value)
+					}
+					if err != nil {
+						return nil, err
+					}
+				}
+			case "br":
+				if cue != nil {
+					text.WriteString("\n")
+				}
+			}
+		case xml.CharData:
+			if cue != nil {
+				text.Write(el)
+			}
+		case xml.EndElement:
+			if el.Name.Local == "p" && cue != nil {
+				lines := []string{}
+				for _, line := range strings.Split(text.String(), "\n") {
+					if line = strings.Join(strings.Fields(line), " "); line != "" {
+						lines = append(lines, line)
+					}
+				}
+				cue.Text = strings.Join(lines, "\n")
+				if cue.Text != "" {
+					cues = append(cues, *cue)
+				}
+				cue = nil
+			}
+		}
+	}
+	return cues, nil
+}
+
+// clock formats d as HH:MM:SS followed by sep and the milliseconds.
+func clock(d time.Duration, sep string) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// WriteSRT writes the cues as SubRip.
+func WriteSRT(w io.Writer, cues []Cue) error {
+	for i, c := range cues {
+		if _, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, clock(c.Start, ","), clock(c.End, ","), c.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVTT writes the cues as WebVTT.
+func WriteVTT(w io.Writer, cues []Cue) error {
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+	for _, c := range cues {
+		if _, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", clock(c.Start, "."), clock(c.End, "."), c.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Convert reads TTML from r and writes it to w in format, SRT or WebVTT.
+func Convert(r io.Reader, w io.Writer, format string) error {
+	cues, err := ParseTTML(r)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case SRT:
+		return WriteSRT(w, cues)
+	case WebVTT:
+		return WriteVTT(w, cues)
+	}
+	return fmt.Errorf("cannot convert TTML to %q", format)
+}
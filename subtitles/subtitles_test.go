@@ -0,0 +1,150 @@
+package subtitles
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ttml wraps paragraphs in a TTML document with the attributes of its tt element.
+func ttml(attrs string, body string) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ` + attrs + `>
+<body><div>` + body + `</div></body>
+</tt>`
+}
+
+func TestParseTTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    []Cue
+		wantErr bool
+	}{
+		{
+			name: "clock times",
+			doc:  ttml("", `<p begin="00:00:01.500" end="00:01:02.25">Hello</p><p begin="01:00:00" end="01:00:03">Bye</p>`),
+			want: []Cue{
+				{Start: 1500 * time.Millisecond, End: time.Minute + 2250*time.Millisecond, Text: "Hello"},
+				{Start: time.Hour, End: time.Hour + 3*time.Second, Text: "Bye"},
+			},
+		},
+		{
+			name: "frames",
+			doc:  ttml(`ttp:frameRate="25"`, `<p begin="00:00:01:05" end="00:00:02:20">Frames</p>`),
+			want: []Cue{{Start: 1200 * time.Millisecond, End: 2800 * time.Millisecond, Text: "Frames"}},
+		},
+		{
+			name: "default frame rate",
+			doc:  ttml("", `<p begin="00:00:00:15" end="30f">Frames</p>`),
+			want: []Cue{{Start: 500 * time.Millisecond, End: time.Second, Text: "Frames"}},
+		},
+		{
+			name: "ticks",
+			doc:  ttml(`ttp:tickRate="10000000"`, `<p begin="15000000t" end="40000000t">Ticks</p>`),
+			want: []Cue{{Start: 1500 * time.Millisecond, End: 4 * time.Second, Text: "Ticks"}},
+		},
+		{
+			name: "offsets",
+			doc:  ttml("", `<p begin="1.5s" end="2000ms">Seconds</p><p begin="1m" end="0.5h">Minutes</p>`),
+			want: []Cue{
+				{Start: 1500 * time.Millisecond, End: 2 * time.Second, Text: "Seconds"},
+				{Start: time.Minute, End: 30 * time.Minute, Text: "Minutes"},
+			},
+		},
+		{
+			name: "line breaks and styling",
+			doc: ttml("", `<p begin="00:00:01.000" end="00:00:02.000"><span style="s1">First   line</span><br/>
+				<span style="s2">second</span> line<br /></p>`),
+			want: []Cue{{Start: time.Second, End: 2 * time.Second, Text: "First line\nsecond line"}},
+		},
+		{
+			name: "empty paragraphs",
+			doc:  ttml("", `<p begin="0s" end="1s"> <br/> </p><p begin="1s" end="2s">Text</p>`),
+			want: []Cue{{Start: time.Second, End: 2 * time.Second, Text: "Text"}},
+		},
+		{
+			name:    "invalid time",
+			doc:     ttml("", `<p begin="soon" end="1s">Text</p>`),
+			wantErr: true,
+		},
+		{
+			name:    "invalid XML",
+			doc:     `<tt><body><p begin="0s" end="1s">Text</body></tt>`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cues, err := ParseTTML(strings.NewReader(tt.doc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(cues, tt.want) {
+				t.Errorf("got %+v, want %+v", cues, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cues := []Cue{
+		{Start: 1500 * time.Millisecond, End: 3 * time.Second, Text: "Hello"},
+		{Start: time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, End: 2 * time.Hour, Text: "Two\nlines"},
+	}
+	tests := []struct {
+		name  string
+		write func(*bytes.Buffer, []Cue) error
+		want  string
+	}{
+		{
+			name:  "srt",
+			write: func(w *bytes.Buffer, cues []Cue) error { return WriteSRT(w, cues) },
+			want: "1\n00:00:01,500 --> 00:00:03,000\nHello\n\n" +
+				"2\n01:02:03,004 --> 02:00:00,000\nTwo\nlines\n\n",
+		},
+		{
+			name:  "vtt",
+			write: func(w *bytes.Buffer, cues []Cue) error { return WriteVTT(w, cues) },
+			want: "WEBVTT\n\n00:00:01.500 --> 00:00:03.000\nHello\n\n" +
+				"01:02:03.004 --> 02:00:00.000\nTwo\nlines\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf, cues); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	doc := ttml("", `<p begin="00:00:01.000" end="00:00:02.000">A<br/>B</p>`)
+	tests := []struct {
+		format, want string
+		wantErr      bool
+	}{
+		{format: SRT, want: "1\n00:00:01,000 --> 00:00:02,000\nA\nB\n\n"},
+		{format: WebVTT, want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nA\nB\n\n"},
+		{format: TTML, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Convert(strings.NewReader(doc), &buf, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}